@@ -49,6 +49,12 @@ class GsDocCommand(sublime_plugin.TextCommand):
 							gs.println('opening %s:%s:%s' % (fn, row, col))
 							gs.focus(fn, row, col)
 							return
+
+						# the source wasn't found, margo synthesized the declaration instead
+						src = d.get('src', '')
+						if src:
+							self.show_output(src)
+							return
 						self.show_output("%s: cannot find definition" % DOMAIN)
 
 					if len(docs) > 1:
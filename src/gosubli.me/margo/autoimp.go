@@ -0,0 +1,238 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type autoImpCand struct {
+	path string
+	// tier orders the candidates by where they're found: the standard library, vendor dirs,
+	// the GOPATH entry of the file, then the other GOPATH entries
+	tier    int
+	matches int
+}
+
+func (c autoImpCand) better(o autoImpCand) bool {
+	switch {
+	case c.matches != o.matches:
+		return c.matches > o.matches
+	case c.tier != o.tier:
+		return c.tier < o.tier
+	case len(c.path) != len(o.path):
+		return len(c.path) < len(o.path)
+	}
+	return c.path < o.path
+}
+
+// autoImports returns the toggles that add imports for the unresolved package selectors in the file e.g. `strings.Split`
+// and remove the imports that aren't used. imports are only removed if the whole file can be parsed
+func autoImports(fn, src string, env map[string]string) []mImportDeclArg {
+	toggle := []mImportDeclArg{}
+	_, af, err := parseAstFile(fn, src, parser.ParseComments)
+	if af == nil || af.Name == nil {
+		return toggle
+	}
+
+	unresolved := map[*ast.Ident]bool{}
+	used := map[string]bool{}
+	for _, id := range af.Unresolved {
+		unresolved[id] = true
+		used[id.Name] = true
+	}
+
+	// the names selected from each unresolved identifier, i.e. the package names that might need to be imported
+	sels := map[string]map[string]bool{}
+	ast.Inspect(af, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && unresolved[id] && ast.IsExported(sel.Sel.Name) {
+				if sels[id.Name] == nil {
+					sels[id.Name] = map[string]bool{}
+				}
+				sels[id.Name][sel.Sel.Name] = true
+			}
+		}
+		return true
+	})
+
+	// like goimports, the names declared at the package level in the package's other files aren't packages
+	fileDir := filepath.Dir(fn)
+	for name := range autoImpPkgDecls(fn, af.Name.Name, env) {
+		delete(sels, name)
+	}
+
+	fileImportPath := ""
+	fileRoot := ""
+	roots := rootDirs(env)
+	for _, root := range roots {
+		if p, err := filepath.Rel(root, fileDir); err == nil && !strings.HasPrefix(p, "..") {
+			fileImportPath = filepath.ToSlash(p)
+			fileRoot = root
+			break
+		}
+	}
+
+	pkgNames := map[string]string{}
+	best := map[string]autoImpCand{}
+	for _, root := range roots {
+		pkgIdx.walk(env, root, true, nil, func(dir string, d *idxDir) {
+			p, err := filepath.Rel(root, dir)
+			if err != nil || p == "." {
+				return
+			}
+
+			ipath, vendored, ok := autoImpPath(filepath.ToSlash(p), fileImportPath, root == fileRoot)
+			// commands can't be imported
+			name := d.pkgName()
+			if !ok || name == "" || name == "main" {
+				return
+			}
+
+			if _, exists := pkgNames[ipath]; !exists {
+				pkgNames[ipath] = name
+			}
+
+			want := sels[name]
+			if len(want) == 0 {
+				return
+			}
+
+			c := autoImpCand{path: ipath, tier: 3}
+			switch {
+			case isStdPkg(ipath):
+				c.tier = 0
+			case vendored:
+				c.tier = 1
+			case root == fileRoot:
+				c.tier = 2
+			}
+			for nm, f := range d.Files {
+				if f.Pkg != name || strings.HasSuffix(nm, "_test.go") {
+					continue
+				}
+				for _, decl := range f.Decls {
+					if decl.Recv == "" && want[decl.Name] {
+						c.matches++
+					}
+				}
+			}
+
+			if b, ok := best[name]; c.matches > 0 && (!ok || c.better(b)) {
+				best[name] = c
+			}
+		})
+	}
+
+	imported := map[string]bool{}
+	for _, decl := range af.Decls {
+		gdecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gdecl.Specs {
+			ispec, ok := spec.(*ast.ImportSpec)
+			if !ok {
+				continue
+			}
+
+			ipath := unquote(ispec.Path.Value)
+			name := pkgNames[ipath]
+			if name == "" {
+				name = path.Base(ipath)
+			}
+			explicitName := ""
+			if ispec.Name != nil {
+				explicitName = ispec.Name.Name
+				name = explicitName
+			}
+			imported[name] = true
+
+			if err == nil && ipath != "C" && name != "_" && name != "." && !used[name] {
+				toggle = append(toggle, mImportDeclArg{
+					Name: explicitName,
+					Path: ipath,
+				})
+			}
+		}
+	}
+
+	for name := range sels {
+		c, ok := best[name]
+		if !ok || imported[name] {
+			continue
+		}
+
+		arg := mImportDeclArg{
+			Path: c.path,
+			Add:  true,
+		}
+		if path.Base(c.path) != name {
+			arg.Name = name
+		}
+		toggle = append(toggle, arg)
+	}
+
+	return toggle
+}
+
+// autoImpPkgDecls returns the package-level names declared in the other files of the package pkg in the directory of fn.
+// test files are only included if fn is a test file
+func autoImpPkgDecls(fn, pkg string, env map[string]string) map[string]bool {
+	names := map[string]bool{}
+	base := filepath.Base(fn)
+	isTest := strings.HasSuffix(strings.ToLower(base), "_test.go")
+	pkgIdx.walk(env, filepath.Dir(fn), false, nil, func(_ string, d *idxDir) {
+		for nm, f := range d.Files {
+			if nm == base || f == nil || f.Ignore || strings.TrimSuffix(f.Pkg, "_test") != strings.TrimSuffix(pkg, "_test") {
+				continue
+			}
+			if !isTest && strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+				continue
+			}
+			for _, decl := range f.Decls {
+				if decl.Recv == "" {
+					names[decl.Name] = true
+				}
+			}
+		}
+	})
+	return names
+}
+
+// autoImpPath returns the path used to import the package at import path p from the package at fileImportPath.
+// ok is false if the package is not importable, i.e. it's testdata or an internal or vendored package that's not visible.
+// sameRoot is true if both packages are in the same GOPATH entry, making the packages in its top-level vendor dir visible
+func autoImpPath(p, fileImportPath string, sameRoot bool) (ipath string, vendored bool, ok bool) {
+	visible := func(parent string) bool {
+		return parent != "" && (fileImportPath == parent || strings.HasPrefix(fileImportPath, parent+"/"))
+	}
+
+	l := strings.Split(p, "/")
+	for i, s := range l {
+		switch s {
+		case "testdata":
+			return "", false, false
+		case "internal":
+			if !visible(strings.Join(l[:i], "/")) {
+				return "", false, false
+			}
+		}
+	}
+
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i] != "vendor" {
+			continue
+		}
+
+		parent := strings.Join(l[:i], "/")
+		if (i == 0 && !sameRoot) || (i != 0 && !visible(parent)) {
+			return "", false, false
+		}
+		return strings.Join(l[i+1:], "/"), true, i+1 < len(l)
+	}
+
+	return p, false, true
+}
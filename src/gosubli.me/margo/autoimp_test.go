@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportsAuto(t *testing.T) {
+	env := fixtureEnv()
+	// keep the standard library out of it
+	env["GOROOT"] = filepath.Join(env["GOPATH"], "goroot")
+
+	src := `package b
+
+import "os"
+
+func f() {
+	a.New(1)
+	main.Run()
+}
+`
+	m := &mImports{
+		Fn:        fixtureFile("ex/b/f.go"),
+		Src:       src,
+		Env:       env,
+		Auto:      true,
+		TabWidth:  8,
+		TabIndent: true,
+	}
+	res, err := m.Call()
+	assert.Equal(t, "", err, "error")
+
+	// os isn't used, and ex/cmd/tool is a command so it can't be imported.
+	// only the source up to the imports is returned
+	want := `package b
+
+import (
+	"ex/a"
+)
+`
+	assert.Equal(t, want, res.(M)["src"], "src")
+}
+
+func TestImportsAutoPkgDecls(t *testing.T) {
+	env := fixtureEnv()
+	env["GOROOT"] = filepath.Join(env["GOPATH"], "goroot")
+
+	// cfg is a var declared in ex/e/conf.go, not the package ex/cfg
+	src := "package e\n\nfunc f() {\n\tcfg.Load()\n\ta.New(1)\n}\n"
+	toggle := autoImports(fixtureFile("ex/e/e.go"), src, env)
+	assert.Equal(t, []mImportDeclArg{{Path: "ex/a", Add: true}}, toggle)
+}
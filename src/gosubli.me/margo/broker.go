@@ -27,6 +27,7 @@ type Response struct {
 type Job struct {
 	Req *Request
 	Cl  Caller
+	Sig *cancelSignal
 }
 
 type Broker struct {
@@ -94,8 +95,9 @@ func (b *Broker) SendNoLog(resp Response) error {
 	return nil
 }
 
-func (b *Broker) call(req *Request, cl Caller) {
+func (b *Broker) call(req *Request, cl Caller, sig *cancelSignal) {
 	b.served.next()
+	defer unwatchJob(req.Token, sig)
 
 	defer func() {
 		err := recover()
@@ -110,7 +112,26 @@ func (b *Broker) call(req *Request, cl Caller) {
 		}
 	}()
 
+	// the request might have been cancelled while it was waiting in the queue
+	if sig.cancelled() {
+		b.Send(Response{
+			Token: req.Token,
+			Error: errCancelled,
+		})
+		return
+	}
+
 	res, err := cl.Call()
+
+	// don't flood the client with stale results
+	if sig.cancelled() {
+		b.Send(Response{
+			Token: req.Token,
+			Error: errCancelled,
+		})
+		return
+	}
+
 	if res == nil {
 		res = M{}
 	} else if v, ok := res.(M); ok && v == nil {
@@ -174,9 +195,21 @@ func (b *Broker) accept(jobsCh chan Job) (stopLooping bool) {
 		return
 	}
 
+	sig := watchJob(req.Token)
+	if c, ok := cl.(cancelableCaller); ok {
+		c.setCancelSignal(sig)
+	}
+
+	// cancellation must not wait behind the jobs it's trying to cancel
+	if req.Method == "cancel" {
+		go b.call(req, cl, sig)
+		return
+	}
+
 	jobsCh <- Job{
 		Req: req,
 		Cl:  cl,
+		Sig: sig,
 	}
 
 	return
@@ -185,7 +218,7 @@ func (b *Broker) accept(jobsCh chan Job) (stopLooping bool) {
 func (b *Broker) worker(wg *sync.WaitGroup, jobsCh chan Job) {
 	defer wg.Done()
 	for job := range jobsCh {
-		b.call(job.Req, job.Cl)
+		b.call(job.Req, job.Cl, job.Sig)
 	}
 }
 
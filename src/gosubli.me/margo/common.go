@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/printer"
 	"go/token"
@@ -93,6 +94,27 @@ func defaultEnv() map[string]string {
 	}
 }
 
+// buildContext returns a copy of build.Default with GOROOT and GOPATH taken from env if they're set
+func buildContext(env map[string]string) *build.Context {
+	ctx := build.Default
+	if p := env["GOROOT"]; p != "" {
+		ctx.GOROOT = p
+	}
+	if p := env["GOPATH"]; p != "" {
+		ctx.GOPATH = p
+	}
+	return &ctx
+}
+
+// gopathSrcDirs returns the src directory of each GOPATH entry in ctx
+func gopathSrcDirs(ctx *build.Context) []string {
+	l := []string{}
+	for _, p := range pathList(ctx.GOPATH) {
+		l = append(l, filepath.Join(p, "src"))
+	}
+	return l
+}
+
 func orString(a ...string) string {
 	for _, s := range a {
 		if s != "" {
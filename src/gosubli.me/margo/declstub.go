@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"strings"
+
+	"gosubli.me/something-borrowed/types"
+)
+
+// lookupSourceDecl returns the position of the declaration of obj, which was imported from export data,
+// in pkg, the same package checked from source.
+// package-level objects are looked up by name, methods and fields in the type that declares them
+func lookupSourceDecl(pkg *types.Package, obj types.Object) token.Pos {
+	kind, _ := parserObjKind(obj)
+	switch kind {
+	case ObjMethod:
+		if T := lookupNamed(pkg, recvTypeName(obj)); T != nil {
+			if m, _, _ := types.LookupFieldOrMethod(T, true, pkg, obj.Name()); m != nil {
+				return m.Pos()
+			}
+		}
+	case ObjField:
+		if v, ok := obj.(*types.Var); ok {
+			name, path := fieldPath(v)
+			if T := lookupNamed(pkg, name); T != nil {
+				if f := structField(T.Underlying(), path); f != nil {
+					return f.Pos()
+				}
+			}
+		}
+	default:
+		if o := pkg.Scope().Lookup(obj.Name()); o != nil {
+			if k, _ := parserObjKind(o); k == kind {
+				return o.Pos()
+			}
+		}
+	}
+	return token.NoPos
+}
+
+// lookupNamed returns the package-level type called name in pkg
+func lookupNamed(pkg *types.Package, name string) *types.Named {
+	if name == "" {
+		return nil
+	}
+	if tn, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok {
+		named, _ := tn.Type().(*types.Named)
+		return named
+	}
+	return nil
+}
+
+// fieldPath returns the name of the package-level struct type that declares the field f
+// and the names of the fields that lead to f, through the anonymous structs nested in it
+func fieldPath(f *types.Var) (name string, path []string) {
+	if f.Pkg() == nil {
+		return "", nil
+	}
+	var find func(st *types.Struct) []string
+	find = func(st *types.Struct) []string {
+		for i := 0; i < st.NumFields(); i++ {
+			fi := st.Field(i)
+			if fi == f {
+				return []string{fi.Name()}
+			}
+			if sub, ok := fi.Type().(*types.Struct); ok {
+				if p := find(sub); p != nil {
+					return append([]string{fi.Name()}, p...)
+				}
+			}
+		}
+		return nil
+	}
+
+	scope := f.Pkg().Scope()
+	for _, nm := range scope.Names() {
+		if tn, ok := scope.Lookup(nm).(*types.TypeName); ok {
+			if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+				if p := find(st); p != nil {
+					return nm, p
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// structField returns the field of the struct type t found by following path, see fieldPath
+func structField(t types.Type, path []string) *types.Var {
+	for i, name := range path {
+		st, ok := t.(*types.Struct)
+		if !ok {
+			return nil
+		}
+		var f *types.Var
+		for j := 0; j < st.NumFields() && f == nil; j++ {
+			if st.Field(j).Name() == name {
+				f = st.Field(j)
+			}
+		}
+		if f == nil || i == len(path)-1 {
+			return f
+		}
+		t = f.Type()
+	}
+	return nil
+}
+
+// recvTypeName returns the name of the receiver's base type if obj is a method
+func recvTypeName(obj types.Object) string {
+	sig, ok := obj.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return ""
+	}
+	t := sig.Recv().Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
+
+// declStub synthesizes the declaration of obj, similar to the output of `go doc`,
+// for objects whose source can't be found e.g. because their package is only installed as an archive
+func declStub(obj types.Object) string {
+	pkg := obj.Pkg()
+	if pkg == nil {
+		return builtinInfoMap[obj.Name()]
+	}
+
+	typeString := func(t types.Type) string {
+		return types.TypeString(pkg, t)
+	}
+	// signature returns the parameters and results of the function type t, without the `func`
+	signature := func(t types.Type) string {
+		return strings.TrimPrefix(typeString(t), "func")
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "package %s // import %q\n\n", pkg.Name(), pkg.Path())
+	switch o := obj.(type) {
+	case *types.Func:
+		sig, _ := o.Type().(*types.Signature)
+		b.WriteString("func ")
+		if sig != nil && sig.Recv() != nil {
+			fmt.Fprintf(b, "(%s) ", strings.TrimSpace(sig.Recv().Name()+" "+typeString(sig.Recv().Type())))
+		}
+		fmt.Fprintf(b, "%s%s\n", o.Name(), signature(o.Type()))
+	case *types.TypeName:
+		fmt.Fprintf(b, "type %s ", o.Name())
+		switch u := o.Type().Underlying().(type) {
+		case *types.Struct:
+			b.WriteString("struct {\n")
+			unexported := false
+			for i := 0; i < u.NumFields(); i++ {
+				f := u.Field(i)
+				switch {
+				case !f.Exported():
+					unexported = true
+				case f.Anonymous():
+					fmt.Fprintf(b, "\t%s\n", typeString(f.Type()))
+				default:
+					fmt.Fprintf(b, "\t%s %s\n", f.Name(), typeString(f.Type()))
+				}
+			}
+			if unexported {
+				b.WriteString("\t// Has unexported fields.\n")
+			}
+			b.WriteString("}\n")
+		case *types.Interface:
+			b.WriteString("interface {\n")
+			for i := 0; i < u.NumExplicitMethods(); i++ {
+				m := u.ExplicitMethod(i)
+				fmt.Fprintf(b, "\t%s%s\n", m.Name(), signature(m.Type()))
+			}
+			b.WriteString("}\n")
+		default:
+			fmt.Fprintf(b, "%s\n", typeString(u))
+		}
+
+		if named, ok := o.Type().(*types.Named); ok && named.NumMethods() != 0 {
+			b.WriteString("\n")
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); m.Exported() {
+					fmt.Fprintf(b, "func (%s) %s%s\n", typeString(m.Type().(*types.Signature).Recv().Type()), m.Name(), signature(m.Type()))
+				}
+			}
+		}
+	case *types.Const:
+		// untyped constants are declared without a type
+		if t, ok := o.Type().(*types.Basic); ok && t.Info()&types.IsUntyped != 0 {
+			fmt.Fprintf(b, "const %s = %s\n", o.Name(), o.Val())
+		} else {
+			fmt.Fprintf(b, "const %s %s = %s\n", o.Name(), typeString(o.Type()), o.Val())
+		}
+	case *types.Var:
+		if o.IsField() {
+			fmt.Fprintf(b, "// field\n%s %s\n", o.Name(), typeString(o.Type()))
+		} else {
+			fmt.Fprintf(b, "var %s %s\n", o.Name(), typeString(o.Type()))
+		}
+	default:
+		fmt.Fprintf(b, "%s\n", types.ObjectString(pkg, obj))
+	}
+	return b.String()
+}
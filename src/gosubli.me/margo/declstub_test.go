@@ -0,0 +1,98 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gosubli.me/something-borrowed/types"
+)
+
+const declStubSrc = `package p
+
+const C = 1
+
+type T struct {
+	F int
+	g string
+}
+
+func (t *T) M(s string) error { return nil }
+
+type U struct{}
+
+func (U) M() {}
+
+type I interface {
+	M() int
+}
+
+func New(n int, s ...string) *T { return nil }
+
+const D int8 = 2
+
+type V struct {
+	F  int
+	In struct {
+		F int
+	}
+}
+`
+
+func checkDeclStubSrc(t *testing.T) (*types.Package, *types.Info, *token.FileSet) {
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, "p.go", declStubSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: map[*ast.Ident]types.Object{}}
+	pkg, err := (&types.Config{}).Check("example.com/p", fset, []*ast.File{af}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg, info, fset
+}
+
+func TestDeclStub(t *testing.T) {
+	pkg, _, _ := checkDeclStubSrc(t)
+	hdr := "package p // import \"example.com/p\"\n\n"
+	tests := map[string]string{
+		"C":   "const C = 1\n",
+		"D":   "const D int8 = 2\n",
+		"New": "func New(n int, s ...string) *T\n",
+		"T":   "type T struct {\n\tF int\n\t// Has unexported fields.\n}\n\nfunc (*T) M(s string) error\n",
+		"I":   "type I interface {\n\tM() int\n}\n",
+	}
+	for name, want := range tests {
+		assert.Equal(t, hdr+want, declStub(pkg.Scope().Lookup(name)), name)
+	}
+
+	m, _, _ := types.LookupFieldOrMethod(pkg.Scope().Lookup("T").Type(), true, pkg, "M")
+	assert.Equal(t, hdr+"func (t *T) M(s string) error\n", declStub(m), "T.M")
+}
+
+func TestLookupSourceDecl(t *testing.T) {
+	// the objects of one check stand in for those imported from export data, the other for the source
+	exp, _, _ := checkDeclStubSrc(t)
+	pkg, _, fset := checkDeclStubSrc(t)
+
+	line := func(obj types.Object) int {
+		return fset.Position(lookupSourceDecl(pkg, obj)).Line
+	}
+	assert.Equal(t, 5, line(exp.Scope().Lookup("T")), "T")
+	assert.Equal(t, 20, line(exp.Scope().Lookup("New")), "New")
+
+	for name, want := range map[string]int{"T": 10, "U": 14} {
+		m, _, _ := types.LookupFieldOrMethod(exp.Scope().Lookup(name).Type(), true, exp, "M")
+		assert.Equal(t, want, line(m), name+".M")
+	}
+	f, _, _ := types.LookupFieldOrMethod(exp.Scope().Lookup("T").Type(), true, exp, "F")
+	assert.Equal(t, 6, line(f), "T.F")
+
+	// fields with the same name and type are told apart by the struct that declares them
+	v := exp.Scope().Lookup("V").Type().Underlying().(*types.Struct)
+	assert.Equal(t, 25, line(v.Field(0)), "V.F")
+	assert.Equal(t, 27, line(v.Field(1).Type().(*types.Struct).Field(0)), "V.In.F")
+}
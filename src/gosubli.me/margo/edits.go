@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// textEdit replaces the text between (Row, Col) and (EndRow, EndCol) with Text.
+// rows and columns are zero-based and columns are byte offsets within the line,
+// the same as the positions reported by lint
+type textEdit struct {
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+	EndRow int    `json:"end_row"`
+	EndCol int    `json:"end_col"`
+	Text   string `json:"text"`
+}
+
+type textEdits []textEdit
+
+func (l textEdits) Len() int {
+	return len(l)
+}
+
+func (l textEdits) Less(i, j int) bool {
+	if l[i].Row == l[j].Row {
+		return l[i].Col < l[j].Col
+	}
+	return l[i].Row < l[j].Row
+}
+
+func (l textEdits) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// posEdit returns an edit that replaces the source between pos and end with text
+func posEdit(fset *token.FileSet, pos, end token.Pos, text string) textEdit {
+	a := fset.Position(pos)
+	b := fset.Position(end)
+	return textEdit{
+		Row:    a.Line - 1,
+		Col:    a.Column - 1,
+		EndRow: b.Line - 1,
+		EndCol: b.Column - 1,
+		Text:   text,
+	}
+}
+
+// lineEdit returns an edit that replaces the whole lines row through endRow (inclusive) with text
+func lineEdit(row, endRow int, text string) textEdit {
+	return textEdit{
+		Row:    row,
+		EndRow: endRow + 1,
+		Text:   text,
+	}
+}
+
+// lineOffsets returns the byte offset of the start of each line in src
+func lineOffsets(src string) []int {
+	l := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			l = append(l, i+1)
+		}
+	}
+	return l
+}
+
+// applyEdits applies edits to src. the edits must not overlap
+func applyEdits(src string, edits []textEdit) (string, error) {
+	lines := lineOffsets(src)
+	offset := func(row, col int) (int, bool) {
+		// allow addressing the (empty) line after the last one so whole lines can be removed
+		if row == len(lines) && col == 0 {
+			return len(src), true
+		}
+		if row < 0 || row >= len(lines) || col < 0 {
+			return 0, false
+		}
+		n := lines[row] + col
+		return n, n <= len(src)
+	}
+
+	l := make(textEdits, len(edits))
+	copy(l, edits)
+	sort.Sort(l)
+
+	buf := make([]byte, 0, len(src))
+	pos := 0
+	for _, e := range l {
+		start, ok1 := offset(e.Row, e.Col)
+		end, ok2 := offset(e.EndRow, e.EndCol)
+		if !ok1 || !ok2 || start > end || start < pos {
+			return "", errors.New("invalid edit")
+		}
+		buf = append(buf, src[pos:start]...)
+		buf = append(buf, e.Text...)
+		pos = end
+	}
+	buf = append(buf, src[pos:]...)
+	return string(buf), nil
+}
+
+// readSrc returns src or, if it's empty, the contents of the file fn
+func readSrc(fn, src string) (string, error) {
+	if src != "" {
+		return src, nil
+	}
+	s, err := ioutil.ReadFile(fn)
+	return string(s), err
+}
+
+// diffMaxD limits the number of differing lines diffEdits looks for before it falls back to
+// replacing all the lines between the common prefix and suffix
+const diffMaxD = 1000
+
+// splitLines splits s into lines, keeping the line endings
+func splitLines(s string) []string {
+	l := []string{}
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		l = append(l, s[:i])
+		s = s[i:]
+	}
+	return l
+}
+
+// diffEdits returns the line edits that turn a into b
+func diffEdits(a, b string) []textEdit {
+	al := splitLines(a)
+	bl := splitLines(b)
+
+	pfx := 0
+	for pfx < len(al) && pfx < len(bl) && al[pfx] == bl[pfx] {
+		pfx++
+	}
+	sfx := 0
+	for sfx < len(al)-pfx && sfx < len(bl)-pfx && al[len(al)-1-sfx] == bl[len(bl)-1-sfx] {
+		sfx++
+	}
+	al = al[pfx : len(al)-sfx]
+	bl = bl[pfx : len(bl)-sfx]
+
+	edits := []textEdit{}
+	hunk := func(i, j, k, l int) {
+		if i < j || k < l {
+			edits = append(edits, textEdit{
+				Row:    pfx + i,
+				EndRow: pfx + j,
+				Text:   strings.Join(bl[k:l], ""),
+			})
+		}
+	}
+
+	matches, ok := diffMatches(al, bl, diffMaxD)
+	if !ok {
+		hunk(0, len(al), 0, len(bl))
+		return edits
+	}
+
+	i, k := 0, 0
+	for _, p := range matches {
+		hunk(i, p[0], k, p[1])
+		i, k = p[0]+1, p[1]+1
+	}
+	hunk(i, len(al), k, len(bl))
+	return edits
+}
+
+// diffMatches returns the indices of the lines common to a and b, in order, using Myers' algorithm.
+// ok is false if a and b differ by more than maxD lines
+func diffMatches(a, b []string, maxD int) (matches [][2]int, ok bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+	for d := 0; d <= max; d++ {
+		if d > maxD {
+			return nil, false
+		}
+
+		// save the furthest points of the previous round, for k in [-d, d]
+		trace = append(trace, append([]int{}, v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+
+			if x >= n && y >= m {
+				return diffBacktrack(a, b, trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func diffBacktrack(a, b []string, trace [][]int, x, y int) [][2]int {
+	matches := [][2]int{}
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		get := func(k int) int {
+			return v[k+d]
+		}
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		matches = append(matches, [2]int{x, y})
+	}
+
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffEdits(t *testing.T) {
+	tests := []struct {
+		a, b string
+		n    int
+	}{
+		{"", "", 0},
+		{"a\nb\nc\n", "a\nb\nc\n", 0},
+		{"a\nb\nc\n", "a\nx\nc\n", 1},
+		{"a\nb\nc\nd\ne\n", "x\nb\nc\ny\ne\nz\n", 3},
+		{"a\nb", "a\nb\n", 1},
+		{"", "package a\n", 1},
+		{"a\nb\nc\n", "", 1},
+		{"b\na\nb\na\n", "a\nb\na\nb\n", 2},
+	}
+
+	for _, tt := range tests {
+		edits := diffEdits(tt.a, tt.b)
+		assert.Len(t, edits, tt.n, tt.a)
+
+		s, err := applyEdits(tt.a, edits)
+		assert.Equal(t, nil, err, tt.a)
+		assert.Equal(t, tt.b, s, tt.a)
+	}
+}
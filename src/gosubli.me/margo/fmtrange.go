@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"unicode"
+)
+
+// fmtRange formats the source between the byte offsets start and end in src, leaving the rest of src untouched.
+// the selection is parsed as a list of declarations, a list of statements or an expression, whichever works first,
+// so the rest of the file need not be valid. the selection keeps its leading and trailing space and is indented
+// like the line it starts on.
+// if rewrite isn't nil, it's applied to the selection before it's printed. the import policy only affects the
+// import declarations in the selection
+func fmtRange(src string, start, end int, tabIndent bool, tabWidth int, rewrite func(*token.FileSet, *ast.File) *ast.File, policy impPolicy) (string, error) {
+	if start < 0 || end > len(src) || start > end {
+		return "", errors.New("invalid range")
+	}
+
+	sel := src[start:end]
+	core := strings.TrimSpace(sel)
+	if core == "" {
+		return src, nil
+	}
+	i := strings.Index(sel, core)
+	leading := sel[:i]
+	trailing := sel[i+len(core):]
+
+	// the indentation of the line containing the first code in the selection
+	lineStart := strings.LastIndex(src[:start+i], "\n") + 1
+	indent := 0
+	spaces := 0
+	for _, c := range src[lineStart:] {
+		if c == '\t' {
+			indent++
+		} else if c == ' ' {
+			spaces++
+		} else {
+			break
+		}
+	}
+	if tabWidth > 0 {
+		indent += spaces / tabWidth
+	}
+
+	s, err := fmtFragment(core, indent, tabIndent, tabWidth, rewrite, policy)
+	if err != nil {
+		return "", err
+	}
+	return src[:start] + leading + s + trailing + src[end:], nil
+}
+
+// fmtFragment formats the declarations, statements or expression s at the indentation level indent.
+// the first line of the result is not indented
+func fmtFragment(s string, indent int, tabIndent bool, tabWidth int, rewrite func(*token.FileSet, *ast.File) *ast.File, policy impPolicy) (string, error) {
+	type context struct {
+		prefix, suffix string
+		// the context indents s by adj more levels than the surrounding code
+		adj int
+	}
+	contexts := []context{
+		{"package p;", "", 0},
+		{"package p; func _() {\n", "\n}", 1},
+		{"package p; var _ = ", "", 0},
+	}
+
+	var err error
+	for _, c := range contexts {
+		fset := token.NewFileSet()
+		af, e := parser.ParseFile(fset, "", c.prefix+s+c.suffix, parser.ParseComments)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+
+		if rewrite != nil {
+			af = rewrite(fset, af)
+		}
+
+		p := newPrinter(tabIndent, tabWidth)
+		p.Indent = indent - c.adj
+		if p.Indent < 0 {
+			p.Indent = 0
+		}
+		buf := &bytes.Buffer{}
+		if e := p.Fprint(buf, fset, af); e != nil {
+			return "", e
+		}
+
+		out := buf.String()
+		if c.adj == 0 && len(af.Imports) != 0 {
+			// the declarations are printed as a whole file
+			if out, err = policy.apply(out, tabIndent, tabWidth); err != nil {
+				return "", err
+			}
+		}
+		lines := strings.Split(strings.TrimRightFunc(out, unicode.IsSpace), "\n")
+		switch c.adj {
+		case 0:
+			// drop the package clause and the blank line after it
+			lines = lines[1:]
+			for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+				lines = lines[1:]
+			}
+			if c.prefix == "package p; var _ = " && len(lines) > 0 {
+				lines[0] = strings.Replace(lines[0], "var _ = ", "", 1)
+			}
+		default:
+			// drop the package clause and the function wrapping the statements
+			for len(lines) > 0 && !strings.HasPrefix(strings.TrimSpace(lines[0]), "func _()") {
+				lines = lines[1:]
+			}
+			if len(lines) >= 2 {
+				lines = lines[1 : len(lines)-1]
+			}
+		}
+
+		if len(lines) == 0 {
+			return s, nil
+		}
+		lines[0] = strings.TrimLeft(lines[0], " \t")
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFmtRange(t *testing.T) {
+	src := "package a\n\nfunc f() {\n\tif x   {\n\ty:=1+2\n\t}\n}\n\nfunc g()   {   x :=   `a\n  b`  }\n\nsyntax error here\n"
+	tests := []struct {
+		sel      string
+		expected string
+	}{
+		{"y:=1+2", "y := 1 + 2"},
+		{"\tif x   {\n\ty:=1+2\n\t}\n", "\tif x {\n\t\ty := 1 + 2\n\t}\n"},
+		{"func g()   {   x :=   `a\n  b`  }", "func g() {\n\tx := `a\n  b`\n}"},
+		{"1+2", "1 + 2"},
+	}
+
+	for _, tt := range tests {
+		start := strings.Index(src, tt.sel)
+		s, err := fmtRange(src, start, start+len(tt.sel), true, 8, nil, impPolicy{})
+		assert.Equal(t, nil, err, tt.sel)
+		assert.Equal(t, src[:start]+tt.expected+src[start+len(tt.sel):], s, tt.sel)
+	}
+
+	_, err := fmtRange(src, 0, len(src), true, 8, nil, impPolicy{})
+	assert.Equal(t, true, err != nil, "syntax error")
+}
+
+func TestFmtRangeOptions(t *testing.T) {
+	src := "package a\n\nimport (\n\t\"example.com/x\"\n\t\"os\"\n)\n\nfunc f(s []int) {\n\tfor i, _ := range s[0:len(s)] {\n\t}\n\tfor i, _ := range s {\n\t}\n}\n"
+	tests := []struct {
+		sel      string
+		expected string
+	}{
+		// the rule applies before the simplification
+		{"for i, _ := range s[0:len(s)] {\n\t}", "for i := range s[0:] {\n\t}"},
+		{"import (\n\t\"example.com/x\"\n\t\"os\"\n)", "import (\n\t\"os\"\n\n\t\"example.com/x\"\n)"},
+	}
+
+	for _, tt := range tests {
+		start := strings.Index(src, tt.sel)
+		m := &mFmt{
+			Src:       src,
+			TabIndent: true,
+			TabWidth:  8,
+			Start:     start,
+			End:       start + len(tt.sel),
+			Simplify:  true,
+			Rewrite:   []string{"a[b:len(a)] -> a[b:]"},
+			impPolicy: impPolicy{ImportGroups: "std"},
+		}
+		res, err := m.Call()
+		assert.Equal(t, "", err, tt.sel)
+		// the rest of the file is left alone
+		assert.Equal(t, src[:start]+tt.expected+src[start+len(tt.sel):], res.(M)["src"], tt.sel)
+	}
+
+	m := &mFmt{Src: src, Start: 1, End: 2, Rewrite: []string{"a + b"}}
+	_, err := m.Call()
+	assert.Equal(t, true, err != "", "invalid rule")
+}
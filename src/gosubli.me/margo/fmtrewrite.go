@@ -0,0 +1,386 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file of the Go distribution, https://golang.org/LICENSE.
+
+// This file is derived from src/cmd/gofmt/simplify.go and src/cmd/gofmt/rewrite.go of the Go distribution.
+// the simplifications and rewrite rules are those of gofmt -s and gofmt -r, changed to count how often each one fires.
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// simplifier applies the gofmt -s simplifications, counting how often each one fired
+type simplifier struct {
+	fired map[string]int
+}
+
+func (s simplifier) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.CompositeLit:
+		// array, slice, and map composite literals may be simplified
+		outer := n
+		var keyType, eltType ast.Expr
+		switch typ := outer.Type.(type) {
+		case *ast.ArrayType:
+			eltType = typ.Elt
+		case *ast.MapType:
+			keyType = typ.Key
+			eltType = typ.Value
+		}
+
+		if eltType != nil {
+			var ktyp reflect.Value
+			if keyType != nil {
+				ktyp = reflect.ValueOf(keyType)
+			}
+			typ := reflect.ValueOf(eltType)
+			for i, x := range outer.Elts {
+				px := &outer.Elts[i]
+				// look at value of indexed/named elements
+				if t, ok := x.(*ast.KeyValueExpr); ok {
+					if keyType != nil {
+						s.simplifyLiteral(ktyp, keyType, t.Key, &t.Key)
+					}
+					x = t.Value
+					px = &t.Value
+				}
+				s.simplifyLiteral(typ, eltType, x, px)
+			}
+			// node was simplified - stop walk (there are no subnodes to simplify)
+			return nil
+		}
+
+	case *ast.SliceExpr:
+		// a slice expression of the form: s[a:len(s)] can be simplified to: s[a:]
+		// if s is "simple enough" (for now we only accept identifiers)
+		if n.Max != nil {
+			break
+		}
+		if x, ok := n.X.(*ast.Ident); ok && x.Obj != nil {
+			if call, ok := n.High.(*ast.CallExpr); ok && len(call.Args) == 1 && !call.Ellipsis.IsValid() {
+				if fun, ok := call.Fun.(*ast.Ident); ok && fun.Name == "len" && fun.Obj == nil {
+					if arg, ok := call.Args[0].(*ast.Ident); ok && arg.Obj == x.Obj {
+						n.High = nil
+						s.fired["slice"]++
+					}
+				}
+			}
+		}
+
+	case *ast.RangeStmt:
+		// for x, _ = range v {...} can be simplified to: for x = range v {...}
+		// for _ = range v {...} can be simplified to: for range v {...}
+		if isBlankIdent(n.Value) {
+			n.Value = nil
+			s.fired["range"]++
+		}
+		if isBlankIdent(n.Key) && n.Value == nil {
+			n.Key = nil
+			s.fired["range"]++
+		}
+	}
+
+	return s
+}
+
+func (s simplifier) simplifyLiteral(typ reflect.Value, astType, x ast.Expr, px *ast.Expr) {
+	ast.Walk(s, x) // simplify x
+
+	// if the element is a composite literal and its literal type matches the outer literal's element type exactly,
+	// the inner literal type may be omitted
+	if inner, ok := x.(*ast.CompositeLit); ok {
+		if rwMatch(nil, typ, reflect.ValueOf(inner.Type)) {
+			inner.Type = nil
+			s.fired["composite literal"]++
+		}
+	}
+
+	// if the outer literal's element type is a pointer type *T and the element is &T{...}, it may be replaced by {...}
+	if ptr, ok := astType.(*ast.StarExpr); ok {
+		if addr, ok := x.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+			if inner, ok := addr.X.(*ast.CompositeLit); ok {
+				if rwMatch(nil, reflect.ValueOf(ptr.X), reflect.ValueOf(inner.Type)) {
+					inner.Type = nil
+					*px = inner
+					s.fired["composite literal"]++
+				}
+			}
+		}
+	}
+}
+
+func isBlankIdent(x ast.Expr) bool {
+	ident, ok := x.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
+
+// simplifyFile applies the gofmt -s simplifications to af and returns the number of times each one fired
+func simplifyFile(af *ast.File) map[string]int {
+	s := simplifier{fired: map[string]int{}}
+	ast.Walk(s, af)
+	return s.fired
+}
+
+// parseRewriteRule parses a rule of the form `pattern -> replacement`.
+// single lowercase letter identifiers in the pattern are wildcards that match any expression
+func parseRewriteRule(rule string) (pattern, replace ast.Expr, err error) {
+	f := strings.Split(rule, "->")
+	if len(f) != 2 {
+		return nil, nil, errors.New("rewrite rule must be of the form 'pattern -> replacement'")
+	}
+	if pattern, err = parser.ParseExpr(strings.TrimSpace(f[0])); err == nil {
+		replace, err = parser.ParseExpr(strings.TrimSpace(f[1]))
+	}
+	return pattern, replace, err
+}
+
+// rewriteFile replaces the expressions in af that match pattern with replace and returns the number of replacements
+func rewriteFile(fset *token.FileSet, pattern, replace ast.Expr, af *ast.File) (*ast.File, int) {
+	cmap := ast.NewCommentMap(fset, af, af.Comments)
+	m := map[string]reflect.Value{}
+	pat := reflect.ValueOf(pattern)
+	repl := reflect.ValueOf(replace)
+	n := 0
+
+	var rewriteVal func(val reflect.Value) reflect.Value
+	rewriteVal = func(val reflect.Value) reflect.Value {
+		// don't bother if val is invalid to start with
+		if !val.IsValid() {
+			return reflect.Value{}
+		}
+		val = rwApply(rewriteVal, val)
+		for k := range m {
+			delete(m, k)
+		}
+		if rwMatch(m, pat, val) {
+			n++
+			return rwSubst(m, repl, reflect.ValueOf(val.Interface().(ast.Node).Pos()))
+		}
+		return val
+	}
+
+	r := rwApply(rewriteVal, reflect.ValueOf(af)).Interface().(*ast.File)
+	r.Comments = cmap.Filter(r).Comments() // recreate comments list
+	return r, n
+}
+
+// rwSet is a wrapper for x.Set(y); it protects the caller from panics if x cannot be changed to y
+func rwSet(x, y reflect.Value) {
+	// don't bother if x cannot be set or y is invalid
+	if !x.CanSet() || !y.IsValid() {
+		return
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			if s, ok := x.(string); ok &&
+				(strings.Contains(s, "type mismatch") || strings.Contains(s, "not assignable")) {
+				// x cannot be set to y - ignore this rewrite
+				return
+			}
+			panic(x)
+		}
+	}()
+	x.Set(y)
+}
+
+// values/types for special cases
+var (
+	rwObjectPtrNil = reflect.ValueOf((*ast.Object)(nil))
+	rwScopePtrNil  = reflect.ValueOf((*ast.Scope)(nil))
+
+	rwIdentType     = reflect.TypeOf((*ast.Ident)(nil))
+	rwObjectPtrType = reflect.TypeOf((*ast.Object)(nil))
+	rwPositionType  = reflect.TypeOf(token.NoPos)
+	rwCallExprType  = reflect.TypeOf((*ast.CallExpr)(nil))
+	rwScopePtrType  = reflect.TypeOf((*ast.Scope)(nil))
+)
+
+// rwApply replaces each AST field x in val with f(x), returning val.
+// to avoid extra conversions, f operates on the reflect.Value form
+func rwApply(f func(reflect.Value) reflect.Value, val reflect.Value) reflect.Value {
+	if !val.IsValid() {
+		return reflect.Value{}
+	}
+
+	// *ast.Objects introduce cycles and are likely incorrect after rewrite; don't follow them but replace with nil instead
+	if val.Type() == rwObjectPtrType {
+		return rwObjectPtrNil
+	}
+
+	// similarly for scopes: they are likely incorrect after a rewrite; replace them with nil
+	if val.Type() == rwScopePtrType {
+		return rwScopePtrNil
+	}
+
+	switch v := reflect.Indirect(val); v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			rwSet(e, f(e))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			e := v.Field(i)
+			rwSet(e, f(e))
+		}
+	case reflect.Interface:
+		e := v.Elem()
+		rwSet(v, f(e))
+	}
+	return val
+}
+
+func rwIsWildcard(s string) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	return size == len(s) && unicode.IsLower(r)
+}
+
+// rwMatch reports whether pattern matches val, recording wildcard submatches in m.
+// if m == nil, rwMatch checks whether pattern == val
+func rwMatch(m map[string]reflect.Value, pattern, val reflect.Value) bool {
+	// wildcard matches any expression. if it appears multiple times in the pattern, it must match the same expression each time
+	if m != nil && pattern.IsValid() && pattern.Type() == rwIdentType {
+		name := pattern.Interface().(*ast.Ident).Name
+		if rwIsWildcard(name) && val.IsValid() {
+			// wildcards only match valid (non-nil) expressions
+			if _, ok := val.Interface().(ast.Expr); ok && !val.IsNil() {
+				if old, ok := m[name]; ok {
+					return rwMatch(nil, old, val)
+				}
+				m[name] = val
+				return true
+			}
+		}
+	}
+
+	// otherwise, pattern and val must match recursively
+	if !pattern.IsValid() || !val.IsValid() {
+		return !pattern.IsValid() && !val.IsValid()
+	}
+	if pattern.Type() != val.Type() {
+		return false
+	}
+
+	// special cases
+	switch pattern.Type() {
+	case rwIdentType:
+		// for identifiers, only the names need to match (and none of the other *ast.Object information in the ident)
+		p := pattern.Interface().(*ast.Ident)
+		v := val.Interface().(*ast.Ident)
+		return p == nil && v == nil || p != nil && v != nil && p.Name == v.Name
+	case rwObjectPtrType, rwPositionType:
+		// object pointers and token positions always match
+		return true
+	case rwCallExprType:
+		// for calls, the Ellipsis fields (token.Position) must match since that is how f(x) and f(x...) are different
+		p := pattern.Interface().(*ast.CallExpr)
+		v := val.Interface().(*ast.CallExpr)
+		if p.Ellipsis.IsValid() != v.Ellipsis.IsValid() {
+			return false
+		}
+	}
+
+	p := reflect.Indirect(pattern)
+	v := reflect.Indirect(val)
+	if !p.IsValid() || !v.IsValid() {
+		return !p.IsValid() && !v.IsValid()
+	}
+
+	switch p.Kind() {
+	case reflect.Slice:
+		if p.Len() != v.Len() {
+			return false
+		}
+		for i := 0; i < p.Len(); i++ {
+			if !rwMatch(m, p.Index(i), v.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		for i := 0; i < p.NumField(); i++ {
+			if !rwMatch(m, p.Field(i), v.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Interface:
+		return rwMatch(m, p.Elem(), v.Elem())
+	}
+
+	// handle token integers, etc.
+	return p.Interface() == v.Interface()
+}
+
+// rwSubst returns a copy of pattern with values from m substituted in place of wildcards and pos used as the position of
+// tokens from the pattern. if m == nil, rwSubst returns a copy of pattern and doesn't change the line number information
+func rwSubst(m map[string]reflect.Value, pattern reflect.Value, pos reflect.Value) reflect.Value {
+	if !pattern.IsValid() {
+		return reflect.Value{}
+	}
+
+	// wildcard gets replaced with map value
+	if m != nil && pattern.Type() == rwIdentType {
+		name := pattern.Interface().(*ast.Ident).Name
+		if rwIsWildcard(name) {
+			if old, ok := m[name]; ok {
+				return rwSubst(nil, old, reflect.Value{})
+			}
+		}
+	}
+
+	if pos.IsValid() && pattern.Type() == rwPositionType {
+		// use new position only if old position was valid in the first place
+		if old := pattern.Interface().(token.Pos); !old.IsValid() {
+			return pattern
+		}
+		return pos
+	}
+
+	// otherwise copy
+	switch p := pattern; p.Kind() {
+	case reflect.Slice:
+		if p.IsNil() {
+			return reflect.Zero(p.Type())
+		}
+		v := reflect.MakeSlice(p.Type(), p.Len(), p.Len())
+		for i := 0; i < p.Len(); i++ {
+			v.Index(i).Set(rwSubst(m, p.Index(i), pos))
+		}
+		return v
+
+	case reflect.Struct:
+		v := reflect.New(p.Type()).Elem()
+		for i := 0; i < p.NumField(); i++ {
+			v.Field(i).Set(rwSubst(m, p.Field(i), pos))
+		}
+		return v
+
+	case reflect.Ptr:
+		v := reflect.New(p.Type()).Elem()
+		if elem := p.Elem(); elem.IsValid() {
+			v.Set(rwSubst(m, elem, pos).Addr())
+		}
+		return v
+
+	case reflect.Interface:
+		v := reflect.New(p.Type()).Elem()
+		if elem := p.Elem(); elem.IsValid() {
+			v.Set(rwSubst(m, elem, pos))
+		}
+		return v
+	}
+
+	return pattern
+}
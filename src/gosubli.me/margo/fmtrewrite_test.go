@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go/parser"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFmtSimplify(t *testing.T) {
+	m := &mFmt{
+		Src:       "package a\n\ntype T struct{ X int }\n\nvar v = []*T{&T{1}, &T{X: 2}}\n\nfunc f(s []int) {\n\tfor i, _ := range s[1:len(s)] {\n\t\t_ = i\n\t}\n\tfor _ = range s {\n\t}\n}\n",
+		TabIndent: true,
+		TabWidth:  8,
+		Simplify:  true,
+	}
+	res, err := m.Call()
+	assert.Equal(t, "", err)
+	assert.Equal(t, "package a\n\ntype T struct{ X int }\n\nvar v = []*T{{1}, {X: 2}}\n\nfunc f(s []int) {\n\tfor i := range s[1:] {\n\t\t_ = i\n\t}\n\tfor range s {\n\t}\n}\n", res.(M)["src"])
+	assert.Equal(t, map[string]int{"composite literal": 2, "slice": 1, "range": 2}, res.(M)["rewrites"])
+}
+
+func TestFmtRewrite(t *testing.T) {
+	m := &mFmt{
+		Src:       "package a\n\nfunc f(s []int) {\n\t_ = s[0:len(s)]\n\t_ = append(s, s...)\n\t_ = bytes.Compare(s, s) == 0\n}\n",
+		TabIndent: true,
+		TabWidth:  8,
+		Rewrite: []string{
+			"a[b:len(a)] -> a[b:]",
+			"bytes.Compare(a, b) == 0 -> bytes.Equal(a, b)",
+			"x.NoMatch -> x",
+		},
+	}
+	res, err := m.Call()
+	assert.Equal(t, "", err)
+	assert.Equal(t, "package a\n\nfunc f(s []int) {\n\t_ = s[0:]\n\t_ = append(s, s...)\n\t_ = bytes.Equal(s, s)\n}\n", res.(M)["src"])
+	assert.Equal(t, map[string]int{"a[b:len(a)] -> a[b:]": 1, "bytes.Compare(a, b) == 0 -> bytes.Equal(a, b)": 1}, res.(M)["rewrites"])
+
+	// a wildcard that appears twice must match the same expression each time
+	fset, af, _ := parseAstFile("", "package a\nvar _ = f(x, y)\n", parser.ParseComments)
+	pattern, replace, e := parseRewriteRule("f(a, a) -> a")
+	assert.Equal(t, nil, e)
+	_, n := rewriteFile(fset, pattern, replace, af)
+	assert.Equal(t, 0, n)
+
+	m.Rewrite = []string{"a + b"}
+	_, err = m.Call()
+	assert.Equal(t, true, err != "", "invalid rule")
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// impPolicy selects how fmt and imports lay out the import declarations
+type impPolicy struct {
+	// ImportGroups is one of:
+	// `` (the default) to leave the import declarations as they are,
+	// `std` to merge them into a single block with the standard library imports grouped before all others and
+	// `local` which is like `std` but imports that start with one of LocalPrefix are placed in a third group
+	ImportGroups string
+	LocalPrefix  []string
+}
+
+type impGroupSpec struct {
+	group   int
+	name    string
+	path    string
+	text    string
+	doc     []string
+	comment string
+}
+
+type impGroupSpecs []impGroupSpec
+
+func (l impGroupSpecs) Len() int {
+	return len(l)
+}
+
+func (l impGroupSpecs) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	switch {
+	case a.group != b.group:
+		return a.group < b.group
+	case a.path != b.path:
+		return a.path < b.path
+	}
+	return a.name < b.name
+}
+
+func (l impGroupSpecs) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (p impPolicy) group(ipath string) int {
+	if p.ImportGroups == "local" {
+		for _, s := range p.LocalPrefix {
+			if s != "" && strings.HasPrefix(ipath, s) {
+				return 2
+			}
+		}
+	}
+
+	if isStdPkg(ipath) || !strings.Contains(strings.SplitN(ipath, "/", 2)[0], ".") {
+		return 0
+	}
+	return 1
+}
+
+// apply rewrites the import declarations in src according to p and returns the re-printed source.
+// comments attached to an import move along with it, other comments in or between the declarations are moved above the block
+func (p impPolicy) apply(src string, tabIndent bool, tabWidth int) (string, error) {
+	switch p.ImportGroups {
+	case "":
+		return src, nil
+	case "std", "local":
+	default:
+		return src, fmt.Errorf("invalid import groups: %q", p.ImportGroups)
+	}
+
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return src, err
+	}
+
+	decls := []*ast.GenDecl{}
+	for _, decl := range af.Decls {
+		if gdecl, ok := decl.(*ast.GenDecl); ok && gdecl.Tok == token.IMPORT {
+			decls = append(decls, gdecl)
+		}
+	}
+	if len(decls) == 0 {
+		return src, nil
+	}
+
+	tf := fset.File(af.Pos())
+	off := func(pos token.Pos) int {
+		return tf.Offset(pos)
+	}
+	text := func(node ast.Node) string {
+		return src[off(node.Pos()):off(node.End())]
+	}
+
+	start := decls[0].Pos()
+	end := decls[len(decls)-1].End()
+	used := map[*ast.CommentGroup]bool{}
+	cDecls := []string{}
+	specs := impGroupSpecs{}
+	seen := map[string]bool{}
+	for _, gdecl := range decls {
+		isC := false
+		for _, spec := range gdecl.Specs {
+			if ispec, ok := spec.(*ast.ImportSpec); ok && unquote(ispec.Path.Value) == "C" {
+				isC = true
+			}
+		}
+		if isC {
+			// the cgo preamble must stay attached to its declaration
+			pos := gdecl.Pos()
+			if gdecl != decls[0] && gdecl.Doc != nil {
+				pos = gdecl.Doc.Pos()
+			}
+			cDecls = append(cDecls, src[off(pos):off(gdecl.End())])
+			for _, cg := range af.Comments {
+				if cg.Pos() >= gdecl.Pos() && cg.End() <= gdecl.End() {
+					used[cg] = true
+				}
+			}
+			continue
+		}
+
+		for _, spec := range gdecl.Specs {
+			ispec, ok := spec.(*ast.ImportSpec)
+			if !ok {
+				continue
+			}
+
+			s := impGroupSpec{
+				path: unquote(ispec.Path.Value),
+				text: text(ispec.Path),
+			}
+			s.group = p.group(s.path)
+			if ispec.Name != nil {
+				s.name = ispec.Name.Name
+				s.text = s.name + " " + s.text
+			}
+			if ispec.Doc != nil {
+				used[ispec.Doc] = true
+				for _, c := range ispec.Doc.List {
+					s.doc = append(s.doc, c.Text)
+				}
+			}
+			if ispec.Comment != nil {
+				used[ispec.Comment] = true
+				s.comment = text(ispec.Comment)
+			}
+
+			key := s.name + " " + s.path
+			if !seen[key] {
+				seen[key] = true
+				specs = append(specs, s)
+			}
+		}
+	}
+	sort.Sort(specs)
+
+	buf := []string{}
+	for _, s := range cDecls {
+		buf = append(buf, s+"\n")
+	}
+	// comments that aren't attached to any import are kept above the block
+	for _, cg := range af.Comments {
+		if cg.Pos() > start && cg.End() < end && !used[cg] {
+			buf = append(buf, text(cg))
+		}
+	}
+
+	if len(specs) == 1 && len(specs[0].doc) == 0 && specs[0].comment == "" {
+		buf = append(buf, "import "+specs[0].text)
+	} else if len(specs) > 0 {
+		l := []string{"import ("}
+		for i, s := range specs {
+			if i > 0 && s.group != specs[i-1].group {
+				l = append(l, "")
+			}
+			for _, c := range s.doc {
+				l = append(l, "\t"+c)
+			}
+			if s.comment != "" {
+				l = append(l, "\t"+s.text+" "+s.comment)
+			} else {
+				l = append(l, "\t"+s.text)
+			}
+		}
+		l = append(l, ")")
+		buf = append(buf, strings.Join(l, "\n"))
+	}
+
+	src = src[:off(start)] + strings.Join(buf, "\n") + src[off(end):]
+	fset, af, err = parseAstFile("", src, parser.ParseComments)
+	if err != nil {
+		return src, err
+	}
+	return printSrc(fset, af, tabIndent, tabWidth)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImpPolicyApply(t *testing.T) {
+	src := `package a
+
+import "os"
+
+import (
+	"github.com/x/y"
+	// doc
+	"fmt" // comment
+	foo "example.com/me/foo"
+	_ "net/http/pprof"
+)
+`
+	expected := `package a
+
+import (
+	// doc
+	"fmt" // comment
+	_ "net/http/pprof"
+	"os"
+
+	"github.com/x/y"
+
+	foo "example.com/me/foo"
+)
+`
+	p := impPolicy{ImportGroups: "local", LocalPrefix: []string{"example.com/me"}}
+	s, err := p.apply(src, true, 8)
+	assert.Equal(t, nil, err, "error")
+	assert.Equal(t, expected, s, "src")
+
+	p = impPolicy{ImportGroups: "std"}
+	s, _ = p.apply("package a\n\nimport (\n\t\"fmt\"\n)\n", true, 8)
+	assert.Equal(t, "package a\n\nimport \"fmt\"\n", s, "single import")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// findImporters returns the import paths of the packages below srcDirs that import importPath.
+// test files are included so the result also covers packages whose tests import importPath
+func findImporters(srcDirs []string, importPath string, sig *cancelSignal) []string {
+	found := map[string]bool{}
+
+	for _, srcDir := range srcDirs {
+		pkgIdx.walk(nil, srcDir, true, sig, func(dir string, d *idxDir) {
+			p, err := filepath.Rel(srcDir, dir)
+			if err != nil || p == "." {
+				return
+			}
+			p = filepath.ToSlash(p)
+			if contains(strings.Split(p, "/"), "testdata") {
+				return
+			}
+
+			for _, f := range d.Files {
+				if contains(f.Imports, importPath) {
+					found[p] = true
+					return
+				}
+			}
+		})
+	}
+
+	l := make([]string, 0, len(found))
+	for p := range found {
+		l = append(l, p)
+	}
+	sort.Strings(l)
+	return l
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	idxVersion = 1
+)
+
+var (
+	// pkgIdx is shared by all requests. it's loaded from, and saved to, the temp dir of the first request that uses it
+	pkgIdx = &pkgIndex{}
+
+	// idxSaveDelay is how long the index is kept in memory after a change before it's saved
+	idxSaveDelay = 5 * time.Second
+)
+
+func init() {
+	// save the changes that are still waiting for idxSaveDelay
+	byeDefer(pkgIdx.save)
+}
+
+// idxDecl is a top-level declaration in a file
+type idxDecl struct {
+	Name string `json:"name"`
+	// Kind is one of func, type, var or const
+	Kind string `json:"kind"`
+	// Recv is the receiver type of methods e.g. `*T`
+	Recv string `json:"recv,omitempty"`
+	// Sig is the signature of funcs, the definition of types and the declared type of vars and consts.
+	// struct and interface definitions are elided
+	Sig string `json:"sig,omitempty"`
+	// Value is the value of consts declared using a short literal
+	Value string `json:"value,omitempty"`
+	Row   int    `json:"row"`
+	Col   int    `json:"col"`
+}
+
+type idxFile struct {
+	Mtime int64  `json:"mtime"`
+	Size  int64  `json:"size"`
+	Pkg   string `json:"pkg"`
+	// Ignore is true if the file has a `+build ignore` constraint
+	Ignore  bool      `json:"ignore,omitempty"`
+	Imports []string  `json:"imports,omitempty"`
+	Decls   []idxDecl `json:"decls,omitempty"`
+}
+
+type idxDir struct {
+	Mtime    int64               `json:"mtime"`
+	Dirs     []string            `json:"dirs,omitempty"`
+	Files    map[string]*idxFile `json:"files"`
+	Archives []string            `json:"archives,omitempty"`
+}
+
+// fileNames returns the names of the go files in d, sorted
+func (d *idxDir) fileNames() []string {
+	l := make([]string, 0, len(d.Files))
+	for nm := range d.Files {
+		l = append(l, nm)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// pkgName returns the name of the package in d, ignoring test files and files with a `+build ignore` constraint
+func (d *idxDir) pkgName() string {
+	for _, nm := range d.fileNames() {
+		f := d.Files[nm]
+		if f.Pkg != "" && !f.Ignore && !strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+			return f.Pkg
+		}
+	}
+	return ""
+}
+
+// declaresInterfaces returns true if d, ignoring test files, declares an interface type
+func (d *idxDir) declaresInterfaces() bool {
+	for nm, f := range d.Files {
+		if f == nil || f.Ignore || strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+			continue
+		}
+		for _, decl := range f.Decls {
+			if decl.Kind == "type" && decl.Sig == "interface" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// declaresMethods returns true if a type in d, ignoring test files, has methods with all the names in methods.
+// promoted methods aren't indexed, so types that get some of them from embedded fields aren't found
+func (d *idxDir) declaresMethods(methods []string) bool {
+	recvs := map[string]map[string]bool{}
+	for nm, f := range d.Files {
+		if f.Ignore || strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+			continue
+		}
+		for _, decl := range f.Decls {
+			if decl.Kind != "func" || decl.Recv == "" {
+				continue
+			}
+			recv := strings.TrimPrefix(decl.Recv, "*")
+			if recvs[recv] == nil {
+				recvs[recv] = map[string]bool{}
+			}
+			recvs[recv][decl.Name] = true
+		}
+	}
+
+	for _, names := range recvs {
+		n := 0
+		for _, m := range methods {
+			if names[m] {
+				n++
+			}
+		}
+		if n == len(methods) {
+			return true
+		}
+	}
+	return false
+}
+
+// pkgIndex is a persistent index of the directories in GOROOT and GOPATH, the packages in them and their declarations.
+// directories and files are only re-read when their mtime changes.
+// walks of the same root are serialized but those of different roots run concurrently. an idxDir isn't modified
+// once it's in the index, a changed directory is replaced by a new one, so the walk callbacks don't need to hold any lock
+type pkgIndex struct {
+	// lck protects the fields below and the Dirs map, but not the walks
+	lck    sync.Mutex
+	roots  map[string]*sync.Mutex
+	fn     string
+	loaded bool
+	dirty  bool
+	// saving is true while a save is scheduled
+	saving bool
+	// saveLck serializes the writes of the index file, they're done without holding lck
+	saveLck sync.Mutex
+
+	Version int                `json:"version"`
+	Dirs    map[string]*idxDir `json:"dirs"`
+}
+
+// walk brings the index of root up-to-date and calls f for it and, if recursive is true, each directory below it
+func (x *pkgIndex) walk(env map[string]string, root string, recursive bool, sig *cancelSignal, f func(dir string, d *idxDir)) {
+	lck := x.rootLock(env, root)
+	lck.Lock()
+	defer lck.Unlock()
+
+	x.walkDir(root, recursive, sig, f)
+	x.scheduleSave()
+}
+
+// rootLock loads the index, if necessary, and returns the lock that serializes the walks of root
+func (x *pkgIndex) rootLock(env map[string]string, root string) *sync.Mutex {
+	x.lck.Lock()
+	defer x.lck.Unlock()
+
+	x.load(env)
+	if x.roots == nil {
+		x.roots = map[string]*sync.Mutex{}
+	}
+	lck := x.roots[root]
+	if lck == nil {
+		lck = &sync.Mutex{}
+		x.roots[root] = lck
+	}
+	return lck
+}
+
+func (x *pkgIndex) walkDir(dir string, recursive bool, sig *cancelSignal, f func(dir string, d *idxDir)) {
+	if sig.cancelled() {
+		return
+	}
+
+	d := x.refresh(dir)
+	if d == nil {
+		return
+	}
+
+	f(dir, d)
+	if recursive {
+		for _, nm := range d.Dirs {
+			x.walkDir(filepath.Join(dir, nm), recursive, sig, f)
+		}
+	}
+}
+
+// refresh updates the index of dir if it, or any of its go files changed
+func (x *pkgIndex) refresh(dir string) *idxDir {
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		x.forget(dir)
+		return nil
+	}
+
+	d := x.get(dir)
+	nd := d
+	if d == nil || d.Mtime != fi.ModTime().UnixNano() {
+		names, ok := ls(dir)
+		if !ok {
+			x.forget(dir)
+			return nil
+		}
+
+		nd = &idxDir{
+			Mtime:    fi.ModTime().UnixNano(),
+			Dirs:     []string{},
+			Files:    map[string]*idxFile{},
+			Archives: []string{},
+		}
+		for _, nm := range names {
+			if nm == "" || nm[0] == '.' || nm[0] == '_' {
+				continue
+			}
+
+			isFx, isGo := fx(nm)
+			switch {
+			case isGo:
+				nd.Files[nm] = nil
+				if d != nil {
+					nd.Files[nm] = d.Files[nm]
+				}
+			case strings.HasSuffix(nm, ".a"):
+				nd.Archives = append(nd.Archives, nm)
+			case !isFx && !ignoreNm(nm):
+				if fi, err := os.Stat(filepath.Join(dir, nm)); err == nil && fi.IsDir() {
+					nd.Dirs = append(nd.Dirs, nm)
+				}
+			}
+		}
+		sort.Strings(nd.Dirs)
+		sort.Strings(nd.Archives)
+
+		if d != nil {
+			for _, nm := range d.Dirs {
+				if !contains(nd.Dirs, nm) {
+					x.forget(filepath.Join(dir, nm))
+				}
+			}
+		}
+	}
+
+	files := nd.Files
+	for nm, f := range files {
+		fn := filepath.Join(dir, nm)
+		var nf *idxFile
+		if fi, err := os.Stat(fn); err == nil {
+			nf = f
+			if f == nil || f.Mtime != fi.ModTime().UnixNano() || f.Size != fi.Size() {
+				nf = indexFile(fn, fi)
+			}
+		}
+		if nf != nil && nf == f {
+			continue
+		}
+
+		if nd == d {
+			nd = d.clone()
+		}
+		if nf == nil {
+			delete(nd.Files, nm)
+		} else {
+			nd.Files[nm] = nf
+		}
+	}
+
+	if nd != d {
+		x.set(dir, nd)
+	}
+	return nd
+}
+
+// clone returns a copy of d whose files can be changed
+func (d *idxDir) clone() *idxDir {
+	c := *d
+	c.Files = make(map[string]*idxFile, len(d.Files))
+	for nm, f := range d.Files {
+		c.Files[nm] = f
+	}
+	return &c
+}
+
+func (x *pkgIndex) get(dir string) *idxDir {
+	x.lck.Lock()
+	defer x.lck.Unlock()
+	return x.Dirs[dir]
+}
+
+func (x *pkgIndex) set(dir string, d *idxDir) {
+	x.lck.Lock()
+	defer x.lck.Unlock()
+	x.Dirs[dir] = d
+	x.dirty = true
+}
+
+// forget removes dir and the directories below it from the index
+func (x *pkgIndex) forget(dir string) {
+	x.lck.Lock()
+	defer x.lck.Unlock()
+
+	pfx := dir + string(filepath.Separator)
+	for k := range x.Dirs {
+		if k == dir || strings.HasPrefix(k, pfx) {
+			delete(x.Dirs, k)
+			x.dirty = true
+		}
+	}
+}
+
+func (x *pkgIndex) load(env map[string]string) {
+	if x.loaded {
+		return
+	}
+
+	x.loaded = true
+	x.fn = filepath.Join(tempDir(env, "index"), "pkgs.json")
+	if s, err := ioutil.ReadFile(x.fn); err == nil {
+		json.Unmarshal(s, x)
+	}
+	if x.Version != idxVersion || x.Dirs == nil {
+		x.Version = idxVersion
+		x.Dirs = map[string]*idxDir{}
+	}
+}
+
+// scheduleSave saves the index idxSaveDelay from now if it changed, so the changes made by the walks in the meantime
+// are written at once
+func (x *pkgIndex) scheduleSave() {
+	x.lck.Lock()
+	defer x.lck.Unlock()
+
+	if x.dirty && !x.saving {
+		x.saving = true
+		time.AfterFunc(idxSaveDelay, x.save)
+	}
+}
+
+func (x *pkgIndex) save() {
+	x.saveLck.Lock()
+	defer x.saveLck.Unlock()
+
+	// the dirs aren't modified once they're in the index, so a copy of the map can be marshalled without holding the lock
+	x.lck.Lock()
+	x.saving = false
+	if !x.dirty {
+		x.lck.Unlock()
+		return
+	}
+	x.dirty = false
+	snapshot := &pkgIndex{
+		Version: x.Version,
+		Dirs:    make(map[string]*idxDir, len(x.Dirs)),
+	}
+	for k, d := range x.Dirs {
+		snapshot.Dirs[k] = d
+	}
+	fn := x.fn
+	x.lck.Unlock()
+
+	s, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+
+	// other instances may be using the same file so write it atomically
+	tmp := fn + "." + strconv.Itoa(os.Getpid())
+	if err := ioutil.WriteFile(tmp, s, 0644); err == nil {
+		if err := os.Rename(tmp, fn); err != nil {
+			os.Remove(tmp)
+		}
+	}
+}
+
+func indexFile(fn string, fi os.FileInfo) *idxFile {
+	f := &idxFile{
+		Mtime:   fi.ModTime().UnixNano(),
+		Size:    fi.Size(),
+		Imports: []string{},
+		Decls:   []idxDecl{},
+	}
+
+	fset := token.NewFileSet()
+	af, _ := parser.ParseFile(fset, fn, nil, parser.ParseComments)
+	if af == nil || af.Name == nil {
+		return f
+	}
+
+	f.Pkg = af.Name.Name
+	f.Imports = fileImportPaths(af)
+	for _, cg := range af.Comments {
+		if cg.Pos() > af.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if buildIgnore.MatchString(c.Text) {
+				f.Ignore = true
+			}
+		}
+	}
+
+	str := func(node ast.Node) string {
+		buf := &bytes.Buffer{}
+		printer.Fprint(buf, fset, node)
+		return buf.String()
+	}
+	decl := func(id *ast.Ident, kind string) idxDecl {
+		tp := fset.Position(id.Pos())
+		return idxDecl{
+			Name: id.Name,
+			Kind: kind,
+			Row:  tp.Line - 1,
+			Col:  tp.Column - 1,
+		}
+	}
+
+	for _, fdecl := range af.Decls {
+		switch n := fdecl.(type) {
+		case *ast.FuncDecl:
+			if n.Name.Name == "_" {
+				continue
+			}
+			d := decl(n.Name, "func")
+			d.Sig = str(n.Type)
+			if n.Recv != nil && len(n.Recv.List) > 0 {
+				d.Recv = str(n.Recv.List[0].Type)
+			}
+			f.Decls = append(f.Decls, d)
+		case *ast.GenDecl:
+			for _, spec := range n.Specs {
+				switch gn := spec.(type) {
+				case *ast.TypeSpec:
+					if gn.Name.Name == "_" {
+						continue
+					}
+					d := decl(gn.Name, "type")
+					switch gn.Type.(type) {
+					case *ast.StructType:
+						d.Sig = "struct"
+					case *ast.InterfaceType:
+						d.Sig = "interface"
+					default:
+						d.Sig = str(gn.Type)
+					}
+					f.Decls = append(f.Decls, d)
+				case *ast.ValueSpec:
+					kind := "var"
+					if n.Tok == token.CONST {
+						kind = "const"
+					}
+					for i, v := range gn.Names {
+						if v.Name == "_" {
+							continue
+						}
+						d := decl(v, kind)
+						if gn.Type != nil {
+							d.Sig = str(gn.Type)
+						}
+						if kind == "const" && i < len(gn.Values) {
+							lit, ok := gn.Values[i].(*ast.BasicLit)
+							if ok && lit.Value != "" && len(lit.Value) <= 64 {
+								d.Value = lit.Value
+							}
+						}
+						f.Decls = append(f.Decls, d)
+					}
+				}
+			}
+		}
+	}
+	return f
+}
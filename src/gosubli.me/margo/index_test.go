@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// idxTestDirs creates a root with a package p in it and an empty temp dir for the index
+func idxTestDirs(t *testing.T) (root, tmp string, cleanup func()) {
+	base, err := ioutil.TempDir("", "margo-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = filepath.Join(base, "root")
+	tmp = filepath.Join(base, "tmp")
+	os.MkdirAll(filepath.Join(root, "p"), 0755)
+	os.MkdirAll(tmp, 0755)
+	ioutil.WriteFile(filepath.Join(root, "p", "a.go"), []byte("package p\n\nfunc A() {}\n"), 0644)
+	return root, tmp, func() { os.RemoveAll(base) }
+}
+
+// idxDeclNames returns the names of the declarations in the package p
+func idxDeclNames(x *pkgIndex, root string, env map[string]string) []string {
+	l := []string{}
+	x.walk(env, filepath.Join(root, "p"), false, nil, func(dir string, d *idxDir) {
+		for _, nm := range d.fileNames() {
+			for _, decl := range d.Files[nm].Decls {
+				l = append(l, decl.Name)
+			}
+		}
+	})
+	return l
+}
+
+func TestPkgIndexMtime(t *testing.T) {
+	root, tmp, cleanup := idxTestDirs(t)
+	defer cleanup()
+	env := map[string]string{"TMPDIR": tmp}
+	x := &pkgIndex{}
+	p := filepath.Join(root, "p")
+
+	assert.Equal(t, []string{"A"}, idxDeclNames(x, root, env), "initial")
+	d := x.get(p)
+
+	// nothing changed so nothing is re-read
+	x.dirty = false
+	assert.Equal(t, []string{"A"}, idxDeclNames(x, root, env), "unchanged")
+	assert.Equal(t, true, x.get(p) == d, "same dir")
+	assert.Equal(t, false, x.dirty, "dirty")
+
+	// the file is re-read when its mtime changes, the dir's isn't
+	fn := filepath.Join(p, "a.go")
+	ioutil.WriteFile(fn, []byte("package p\n\nfunc B() {}\n"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(fn, later, later)
+	assert.Equal(t, []string{"B"}, idxDeclNames(x, root, env), "file changed")
+	assert.Equal(t, true, x.get(p) != d, "dirs are replaced, not modified")
+	assert.Equal(t, []string{"A"}, idxDirDecls(d), "old dir")
+
+	// new files are found when the dir's mtime changes
+	ioutil.WriteFile(filepath.Join(p, "c.go"), []byte("package p\n\nvar C int\n"), 0644)
+	os.Chtimes(p, later, later)
+	assert.Equal(t, []string{"B", "C"}, idxDeclNames(x, root, env), "file added")
+
+	os.RemoveAll(p)
+	assert.Equal(t, []string{}, idxDeclNames(x, root, env), "dir removed")
+	assert.Equal(t, true, x.get(p) == nil, "forgotten")
+}
+
+func idxDirDecls(d *idxDir) []string {
+	l := []string{}
+	for _, nm := range d.fileNames() {
+		for _, decl := range d.Files[nm].Decls {
+			l = append(l, decl.Name)
+		}
+	}
+	return l
+}
+
+func TestPkgIndexSave(t *testing.T) {
+	root, tmp, cleanup := idxTestDirs(t)
+	defer cleanup()
+	env := map[string]string{"TMPDIR": tmp}
+
+	defer func(d time.Duration) { idxSaveDelay = d }(idxSaveDelay)
+	idxSaveDelay = time.Hour
+
+	x := &pkgIndex{}
+	idxDeclNames(x, root, env)
+	_, err := os.Stat(x.fn)
+	assert.Equal(t, true, os.IsNotExist(err), "saved before the delay")
+	assert.Equal(t, true, x.saving, "save scheduled")
+
+	// that's what the timer does
+	x.save()
+	assert.Equal(t, false, x.saving || x.dirty, "saved")
+
+	// an unchanged walk doesn't schedule another save
+	idxDeclNames(x, root, env)
+	assert.Equal(t, false, x.saving, "save scheduled after an unchanged walk")
+
+	y := &pkgIndex{}
+	y.rootLock(env, root)
+	assert.Equal(t, x.fn, y.fn, "fn")
+	if assert.NotNil(t, y.get(filepath.Join(root, "p")), "loaded") {
+		assert.Equal(t, []string{"A"}, idxDirDecls(y.get(filepath.Join(root, "p"))), "loaded decls")
+	}
+
+	// the delay is short in normal operation
+	idxSaveDelay = time.Millisecond
+	z := &pkgIndex{}
+	idxDeclNames(z, root, map[string]string{"TMPDIR": filepath.Join(tmp, "z")})
+	saved := false
+	for i := 0; i < 100 && !saved; i++ {
+		time.Sleep(10 * time.Millisecond)
+		z.lck.Lock()
+		saved = !z.saving && !z.dirty
+		z.lck.Unlock()
+	}
+	assert.Equal(t, true, saved, "saved after the delay")
+	_, err = os.Stat(z.fn)
+	assert.Equal(t, nil, err, "saved file")
+}
+
+func TestPkgIndexRoots(t *testing.T) {
+	root, tmp, cleanup := idxTestDirs(t)
+	defer cleanup()
+	env := map[string]string{"TMPDIR": tmp}
+	x := &pkgIndex{}
+
+	// a walk of another root isn't blocked by the one in progress
+	done := make(chan []string)
+	x.walk(env, root, false, nil, func(dir string, d *idxDir) {
+		go func() {
+			done <- idxDeclNames(x, root, env)
+		}()
+		select {
+		case l := <-done:
+			assert.Equal(t, []string{"A"}, l, "decls")
+		case <-time.After(5 * time.Second):
+			t.Error("the walks of different roots are serialized")
+		}
+	})
+}
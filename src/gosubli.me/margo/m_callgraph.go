@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gosubli.me/something-borrowed/types"
+)
+
+// mCallgraph returns the direct callers and callees of the function under the cursor.
+// each function in the result has a token that can be passed back as Node to expand it in turn
+type mCallgraph struct {
+	Fn     string
+	Src    interface{}
+	Env    map[string]string
+	Offset int
+	Node   string
+
+	cancelable
+}
+
+type callSite struct {
+	Fn  string `json:"fn"`
+	Row int    `json:"row"`
+	Col int    `json:"col"`
+}
+
+type callNode struct {
+	Token string `json:"token"`
+	Pkg   string `json:"pkg"`
+	Name  string `json:"name"`
+	Fn    string `json:"fn"`
+	Row   int    `json:"row"`
+	Col   int    `json:"col"`
+	// Dynamic is true if the call is made through an interface and may not actually reach this function
+	Dynamic bool       `json:"dynamic"`
+	Sites   []callSite `json:"sites"`
+}
+
+type callNodes []*callNode
+
+func (l callNodes) Len() int {
+	return len(l)
+}
+
+func (l callNodes) Less(i, j int) bool {
+	if l[i].Pkg != l[j].Pkg {
+		return l[i].Pkg < l[j].Pkg
+	}
+	return l[i].Name < l[j].Name
+}
+
+func (l callNodes) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (m *mCallgraph) Call() (interface{}, string) {
+	res := M{}
+	if m.Node != "" {
+		i := strings.LastIndex(m.Node, ":")
+		n, err := strconv.Atoi(m.Node[i+1:])
+		if i < 0 || err != nil {
+			return res, fmt.Sprintf("invalid node: %q", m.Node)
+		}
+		m.Fn = m.Node[:i]
+		m.Offset = n
+		m.Src = nil
+	}
+
+	ctx := buildContext(m.Env)
+	w := NewPkgWalker(ctx, false, false, false)
+	w.sig = m.sig
+	cursor := &FileCursor{
+		src:       m.Src,
+		cursorPos: m.Offset,
+		fileName:  filepath.Base(m.Fn),
+		fileDir:   filepath.Dir(m.Fn),
+	}
+
+	target, pkgs, err := w.workspacePkgs(cursor, gopathSrcDirs(ctx))
+	if err != nil {
+		return res, err.Error()
+	}
+
+	r := &objRefs{
+		w:      w,
+		target: target,
+		pkgs:   pkgs,
+	}
+	r.resolveTarget()
+	fn, ok := r.target.(*types.Func)
+	if !ok {
+		return res, fmt.Sprintf("%s is not a function", r.target.Name())
+	}
+
+	g := &callGraph{
+		objRefs: r,
+		callers: map[string]*callNode{},
+		callees: map[string]*callNode{},
+		impls:   map[*types.Interface][]implementation{},
+	}
+	g.collect(fn)
+	if w.sig.cancelled() {
+		return res, errCancelled
+	}
+
+	res["node"] = g.node(fn, false)
+	res["callers"] = g.sorted(g.callers)
+	res["callees"] = g.sorted(g.callees)
+	return res, ""
+}
+
+func init() {
+	registry.Register("callgraph", func(_ *Broker) Caller {
+		return &mCallgraph{
+			Env: map[string]string{},
+		}
+	})
+}
+
+type callGraph struct {
+	*objRefs
+	callers map[string]*callNode
+	callees map[string]*callNode
+	impls   map[*types.Interface][]implementation
+}
+
+// collect finds the calls made by and to fn in the loaded packages
+func (g *callGraph) collect(fn *types.Func) {
+	for _, p := range g.pkgs {
+		for _, f := range infoFiles(p.info) {
+			for _, decl := range f.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Body == nil {
+					continue
+				}
+
+				caller, _ := p.info.Defs[fd.Name].(*types.Func)
+				isTarget := g.isRef(caller)
+				ast.Inspect(fd.Body, func(node ast.Node) bool {
+					call, ok := node.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					pos := call.Pos()
+					if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok {
+						pos = sel.Sel.Pos()
+					}
+					for _, c := range g.resolve(p.info, call) {
+						if isTarget {
+							g.add(g.callees, c.fn, c.dynamic, pos)
+						}
+						if caller != nil && g.isRef(c.fn) {
+							g.add(g.callers, caller, c.dynamic, pos)
+						}
+					}
+					return true
+				})
+			}
+		}
+	}
+}
+
+type callee struct {
+	fn      *types.Func
+	dynamic bool
+}
+
+// resolve returns the functions that call may reach. calls through an interface are approximated
+// by the methods of the loaded types that implement it
+func (g *callGraph) resolve(info *types.Info, call *ast.CallExpr) []callee {
+	var obj types.Object
+	switch x := unparen(call.Fun).(type) {
+	case *ast.Ident:
+		obj = info.Uses[x]
+	case *ast.SelectorExpr:
+		if sel, ok := info.Selections[x]; ok {
+			obj = sel.Obj()
+		} else {
+			obj = info.Uses[x.Sel]
+		}
+	}
+
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+
+	l := []callee{{fn: fn}}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return l
+	}
+	iface, ok := recv.Type().Underlying().(*types.Interface)
+	if !ok {
+		return l
+	}
+
+	impls, ok := g.impls[iface]
+	if !ok {
+		impls = g.w.implementations(iface)
+		g.impls[iface] = impls
+	}
+	for _, impl := range impls {
+		var t types.Type = impl.obj.Type()
+		if impl.pointer {
+			t = types.NewPointer(t)
+		}
+		if m, _, _ := types.LookupFieldOrMethod(t, false, fn.Pkg(), fn.Name()); m != nil {
+			if m, ok := m.(*types.Func); ok {
+				l = append(l, callee{fn: m, dynamic: true})
+			}
+		}
+	}
+	return l
+}
+
+func (g *callGraph) add(nodes map[string]*callNode, fn *types.Func, dynamic bool, pos token.Pos) {
+	path, _ := funcPkg(fn)
+	key := path + "." + funcName(fn) + "@" + strconv.Itoa(int(fn.Pos()))
+	n := nodes[key]
+	if n == nil {
+		n = g.node(fn, dynamic)
+		nodes[key] = n
+	}
+	n.Dynamic = n.Dynamic && dynamic
+
+	tp := g.w.fset.Position(pos)
+	site := callSite{Fn: tp.Filename, Row: tp.Line - 1, Col: tp.Column - 1}
+	for _, s := range n.Sites {
+		if s == site {
+			return
+		}
+	}
+	n.Sites = append(n.Sites, site)
+}
+
+func (g *callGraph) node(fn *types.Func, dynamic bool) *callNode {
+	_, pkg := funcPkg(fn)
+	n := &callNode{
+		Pkg:     pkg,
+		Name:    funcName(fn),
+		Dynamic: dynamic,
+		Sites:   []callSite{},
+	}
+	if fn.Pos().IsValid() {
+		tp := g.w.fset.Position(fn.Pos())
+		n.Fn = tp.Filename
+		n.Row = tp.Line - 1
+		n.Col = tp.Column - 1
+		n.Token = fmt.Sprintf("%s:%d", tp.Filename, tp.Offset)
+	}
+	return n
+}
+
+func (g *callGraph) sorted(nodes map[string]*callNode) callNodes {
+	l := callNodes{}
+	for _, n := range nodes {
+		l = append(l, n)
+	}
+	sort.Sort(l)
+	return l
+}
+
+// funcPkg returns the import path and name of fn's package.
+// the methods of universe types, i.e. error.Error, have no package so they're put in `builtin`, like godoc does
+func funcPkg(fn *types.Func) (path, name string) {
+	if p := fn.Pkg(); p != nil {
+		return p.Path(), p.Name()
+	}
+	return "builtin", "builtin"
+}
+
+// funcName returns the name of fn, methods are named `(T).Name`
+func funcName(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return fn.Name()
+	}
+
+	t := recv.Type()
+	ptr := ""
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+		ptr = "*"
+	}
+	if named, ok := t.(*types.Named); ok {
+		return "(" + ptr + named.Obj().Name() + ")." + fn.Name()
+	}
+	return fn.Name()
+}
+
+// infoFiles returns the files whose scopes are recorded in info
+func infoFiles(info *types.Info) []*ast.File {
+	l := []*ast.File{}
+	for node := range info.Scopes {
+		if f, ok := node.(*ast.File); ok {
+			l = append(l, f)
+		}
+	}
+	return l
+}
+
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// callNodeStrings describes each node as `pkg.name@file:row`, calls through an interface are prefixed by `~`
+func callNodeStrings(l callNodes) []string {
+	s := []string{}
+	for _, n := range l {
+		d := ""
+		if n.Dynamic {
+			d = "~"
+		}
+		s = append(s, fmt.Sprintf("%s%s.%s@%s:%d", d, n.Pkg, n.Name, filepath.Base(n.Fn), n.Row))
+	}
+	return s
+}
+
+func TestCallgraph(t *testing.T) {
+	m := &mCallgraph{
+		Fn:     fixtureFile("ex/c/err.go"),
+		Env:    fixtureEnv(),
+		Offset: fixtureOffset(t, "ex/c/err.go", "func |Describe"),
+	}
+	raw, err := m.Call()
+	if !assert.Equal(t, "", err, "error") {
+		return
+	}
+
+	res := raw.(M)
+	assert.Equal(t, "Describe", res["node"].(*callNode).Name, "node")
+	assert.Equal(t, []string{"c.describeE@err.go:13"}, callNodeStrings(res["callers"].(callNodes)), "callers")
+	// error.Error has no package, nor a position
+	assert.Equal(t, []string{"builtin.(error).Error@.:0", "~c.(E).Error@err.go:4"}, callNodeStrings(res["callees"].(callNodes)), "callees")
+}
+
+func TestCallgraphNode(t *testing.T) {
+	m := &mCallgraph{
+		Env:  fixtureEnv(),
+		Node: fmt.Sprintf("%s:%d", fixtureFile("ex/c/err.go"), fixtureOffset(t, "ex/c/err.go", "func |describeE")),
+	}
+	raw, err := m.Call()
+	if assert.Equal(t, "", err, "error") {
+		assert.Equal(t, []string{"c.Describe@err.go:9"}, callNodeStrings(raw.(M)["callees"].(callNodes)), "callees")
+	}
+
+	m = &mCallgraph{Node: "err.go"}
+	_, err = m.Call()
+	assert.Equal(t, `invalid node: "err.go"`, err)
+}
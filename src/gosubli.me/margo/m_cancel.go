@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const (
+	// errCancelled is the error reported to the client for requests that were cancelled
+	// before or during their execution
+	errCancelled = "cancelled"
+)
+
+var (
+	// cancelledErr is returned by long-running helpers (e.g. PkgWalker.Import) that were aborted
+	cancelledErr = errors.New(errCancelled)
+
+	jobWatchlist = map[string]*cancelSignal{}
+	jobWatchLck  = sync.Mutex{}
+)
+
+// cancelSignal is closed when the request it belongs to is cancelled.
+// a nil *cancelSignal is valid and is never cancelled
+type cancelSignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func newCancelSignal() *cancelSignal {
+	return &cancelSignal{
+		ch: make(chan struct{}),
+	}
+}
+
+func (c *cancelSignal) cancel() {
+	if c != nil {
+		c.once.Do(func() {
+			close(c.ch)
+		})
+	}
+}
+
+func (c *cancelSignal) done() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.ch
+}
+
+func (c *cancelSignal) cancelled() bool {
+	if c == nil {
+		return false
+	}
+
+	select {
+	case <-c.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// context returns a context that's done when c is cancelled, e.g. to abort http requests
+func (c *cancelSignal) context() context.Context {
+	return cancelContext{Context: context.Background(), sig: c}
+}
+
+type cancelContext struct {
+	context.Context
+	sig *cancelSignal
+}
+
+func (c cancelContext) Done() <-chan struct{} {
+	return c.sig.done()
+}
+
+func (c cancelContext) Err() error {
+	if c.sig.cancelled() {
+		return context.Canceled
+	}
+	return nil
+}
+
+// cancelable is embedded by Callers that are able to abort their work early.
+// the broker sets the signal before the request is queued
+type cancelable struct {
+	sig *cancelSignal
+}
+
+func (c *cancelable) setCancelSignal(sig *cancelSignal) {
+	c.sig = sig
+}
+
+type cancelableCaller interface {
+	setCancelSignal(sig *cancelSignal)
+}
+
+type mCancel struct {
+	Token string
+}
+
+func (m *mCancel) Call() (res interface{}, err string) {
+	res = M{
+		m.Token: cancelJob(m.Token),
+	}
+	return
+}
+
+func watchJob(token string) *cancelSignal {
+	sig := newCancelSignal()
+	if token == "" {
+		return sig
+	}
+
+	jobWatchLck.Lock()
+	defer jobWatchLck.Unlock()
+
+	// if the client re-used a token, the older request is superseded
+	if old, ok := jobWatchlist[token]; ok {
+		old.cancel()
+	}
+	jobWatchlist[token] = sig
+	return sig
+}
+
+func unwatchJob(token string, sig *cancelSignal) {
+	if token == "" {
+		return
+	}
+
+	jobWatchLck.Lock()
+	defer jobWatchLck.Unlock()
+
+	if jobWatchlist[token] == sig {
+		delete(jobWatchlist, token)
+	}
+}
+
+func cancelJob(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	jobWatchLck.Lock()
+	defer jobWatchLck.Unlock()
+
+	if sig, ok := jobWatchlist[token]; ok {
+		sig.cancel()
+		return true
+	}
+	return false
+}
+
+func init() {
+	registry.Register("cancel", func(_ *Broker) Caller {
+		return &mCancel{}
+	})
+}
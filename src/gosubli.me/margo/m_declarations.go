@@ -34,20 +34,23 @@ func (m *mDeclarations) Call() (interface{}, string) {
 		fileDecls = m.collectDecls(fset, af, fileDecls)
 	}
 
-	fset := token.NewFileSet()
 	if m.PkgDir != "" {
-		var pkgs map[string]*ast.Package
-
-		if fi, err := os.Stat(m.PkgDir); err == nil && fi.IsDir() {
-			_, pkgs, _ = parsePkg(fset, m.PkgDir, 0)
-		} else {
-			_, pkgs, _ = findPkg(fset, m.PkgDir, rootDirs(m.Env), 0)
+		dir := m.PkgDir
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			dir = ""
+			for _, root := range rootDirs(m.Env) {
+				fn := filepath.Join(root, m.PkgDir)
+				if fi, err := os.Stat(fn); err == nil && fi.IsDir() {
+					dir = fn
+					break
+				}
+			}
 		}
 
-		for _, pkg := range pkgs {
-			for _, af := range pkg.Files {
-				pkgDecls = m.collectDecls(fset, af, pkgDecls)
-			}
+		if dir != "" {
+			pkgIdx.walk(m.Env, dir, false, nil, func(dir string, d *idxDir) {
+				pkgDecls = m.indexDecls(dir, d, pkgDecls)
+			})
 		}
 	}
 
@@ -143,6 +146,53 @@ func (m *mDeclarations) collectDecls(fset *token.FileSet, af *ast.File, decls []
 	return decls
 }
 
+// indexDecls returns the declarations of the package in d.
+// like parsePkg, only the package named after the directory, or main, is considered
+func (m *mDeclarations) indexDecls(dir string, d *idxDir, decls []*mDeclarationsDecl) []*mDeclarationsDecl {
+	names := d.fileNames()
+	pkgName := filepath.Base(dir)
+	found := false
+	for _, nm := range names {
+		if d.Files[nm].Pkg == pkgName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		pkgName = "main"
+	}
+
+	for _, nm := range names {
+		f := d.Files[nm]
+		if f.Pkg != pkgName {
+			continue
+		}
+
+		fn := filepath.Join(dir, nm)
+		for _, id := range f.Decls {
+			decl := &mDeclarationsDecl{
+				Name: id.Name,
+				Kind: m.kind(ast.NewIdent(id.Name), id.Kind),
+				Fn:   fn,
+				Row:  id.Row,
+				Col:  id.Col,
+			}
+
+			switch {
+			case id.Recv != "":
+				decl.Repr = "(" + id.Recv + ")." + id.Name
+			case id.Kind == "func" && id.Name == "init":
+				decl.Name += " (" + nm + ")"
+			case id.Value != "":
+				decl.Name += " (" + id.Value + ")"
+			}
+
+			decls = append(decls, decl)
+		}
+	}
+	return decls
+}
+
 func (m *mDeclarations) kind(id *ast.Ident, k string) string {
 	if id.IsExported() {
 		return "+ " + k
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"go/ast"
+	"go/token"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"gosubli.me/something-borrowed/types"
 )
 
 type Doc struct {
@@ -12,6 +19,28 @@ type Doc struct {
 	Fn   string `json:"fn"`
 	Row  int    `json:"row"`
 	Col  int    `json:"col"`
+	Func string `json:"func,omitempty"`
+}
+
+type Docs []*Doc
+
+func (l Docs) Len() int {
+	return len(l)
+}
+
+func (l Docs) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.Fn != b.Fn {
+		return a.Fn < b.Fn
+	}
+	if a.Row != b.Row {
+		return a.Row < b.Row
+	}
+	return a.Col < b.Col
+}
+
+func (l Docs) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
 }
 
 type mDoc struct {
@@ -24,9 +53,27 @@ type mDoc struct {
 	FindDef   bool
 	FindUse   bool
 	FindInfo  bool
+
+	// Scope limits where usages are searched for:
+	// `package` (the default) only searches the package of the current file,
+	// `gopath` also searches the packages in the GOPATH entry of the current file that import the defining package and
+	// `workspace` searches the importing packages in all GOPATH entries
+	Scope string
+
+	cancelable
 }
 
 func (m *mDoc) Call() (interface{}, string) {
+	if m.FindUse {
+		switch m.Scope {
+		case "", "package":
+		case "gopath", "workspace":
+			return m.findUses()
+		default:
+			return []*Doc{}, fmt.Sprintf("invalid scope: %q", m.Scope)
+		}
+	}
+
 	// get the path from our current filename
 	res := m.findCode([]string{filepath.Dir(m.Fn)})
 	return res, ""
@@ -47,3 +94,77 @@ func init() {
 		}
 	})
 }
+
+// findUses returns the references to the object under the cursor in its defining package
+// and the packages that import it
+func (m *mDoc) findUses() (interface{}, string) {
+	res := []*Doc{}
+	ctx := buildContext(m.Env)
+	w := NewPkgWalker(ctx, false, true, false)
+	w.sig = m.sig
+	cursor := &FileCursor{
+		src:       m.Src,
+		cursorPos: m.Offset,
+		fileName:  filepath.Base(m.Fn),
+		fileDir:   filepath.Dir(m.Fn),
+	}
+
+	srcDirs := []string{}
+	for _, dir := range gopathSrcDirs(ctx) {
+		if m.Scope == "gopath" && !strings.HasPrefix(cursor.fileDir, dir+string(filepath.Separator)) {
+			continue
+		}
+		srcDirs = append(srcDirs, dir)
+	}
+
+	target, pkgs, err := w.workspacePkgs(cursor, srcDirs)
+	if err != nil {
+		return res, err.Error()
+	}
+	if w.sig.cancelled() {
+		return res, errCancelled
+	}
+
+	r := &objRefs{
+		w:      w,
+		target: target,
+		pkgs:   pkgs,
+	}
+	r.resolveTarget()
+
+	kind, _ := parserObjKind(r.target)
+	r.refs(func(_ typesPkg, id *ast.Ident) {
+		tp := w.fset.Position(id.Pos())
+		res = append(res, &Doc{
+			Pkg:  r.target.Pkg().Name(),
+			Name: id.Name,
+			Kind: kind.String(),
+			Fn:   tp.Filename,
+			Row:  tp.Line - 1,
+			Col:  tp.Column - 1,
+			Func: w.enclosingFunc(id.Pos()),
+		})
+	})
+	sort.Sort(Docs(res))
+	return res, ""
+}
+
+// enclosingFunc returns the name of the function declaration containing pos, methods are named `(T).Name`
+func (w *PkgWalker) enclosingFunc(pos token.Pos) string {
+	f := w.parsedFileCache[w.fset.Position(pos).Filename]
+	if f == nil {
+		return ""
+	}
+
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || pos < fd.Pos() || pos > fd.End() {
+			continue
+		}
+		if fd.Recv != nil && len(fd.Recv.List) != 0 {
+			return "(" + types.ExprString(fd.Recv.List[0].Type) + ")." + fd.Name.Name
+		}
+		return fd.Name.Name
+	}
+	return ""
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -116,3 +117,65 @@ func TestUsages_Import(t *testing.T) {
 		[]*Doc{&Doc{Src: "", Pkg: "testing", Name: "fmt", Kind: "package", Fn: "simple.go", Row: 11, Col: 1}},
 	)
 }
+
+func TestUsages_Scopes(t *testing.T) {
+	env := fixtureEnv()
+	env["GOPATH"] += string(filepath.ListSeparator) + filepath.Join(env["GOPATH"], "other")
+
+	// each usage is described as `file:row:func`
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"package", []string{"a.go:9:", "a.go:25:New"}},
+		{"gopath", []string{"a.go:9:", "a.go:25:New", "b.go:7:Val"}},
+		// usages are sorted by file name, other/src/ex2/d sorts before src/ex/a
+		{"workspace", []string{"d.go:8:(D).Val", "a.go:9:", "a.go:25:New", "b.go:7:Val"}},
+	}
+
+	for _, tt := range tests {
+		m := &mDoc{
+			Fn:      fixtureFile("ex/a/a.go"),
+			Env:     env,
+			Offset:  fixtureOffset(t, "ex/a/a.go", "Val  int"),
+			FindUse: true,
+			Scope:   tt.scope,
+		}
+		raw, err := m.Call()
+		assert.Equal(t, "", err, tt.scope)
+
+		got := []string{}
+		for _, d := range raw.([]*Doc) {
+			got = append(got, fmt.Sprintf("%s:%d:%s", filepath.Base(d.Fn), d.Row, d.Func))
+		}
+		assert.Equal(t, tt.want, got, tt.scope)
+	}
+}
+
+func TestUsages_InvalidScope(t *testing.T) {
+	m := &mDoc{
+		Fn:      fixtureFile("ex/a/a.go"),
+		Env:     fixtureEnv(),
+		FindUse: true,
+		Scope:   "universe",
+	}
+	_, err := m.Call()
+	assert.Equal(t, `invalid scope: "universe"`, err)
+}
+
+func TestPkgWalkerLenient(t *testing.T) {
+	for _, lenient := range []bool{false, true} {
+		w := NewPkgWalker(buildContext(fixtureEnv()), false, false, false)
+		cursor := &FileCursor{
+			src:       "package a\n\nvar New2 = New\n",
+			fileName:  "new.go",
+			fileDir:   filepath.Dir(fixtureFile("ex/a/a.go")),
+			cursorPos: 12,
+		}
+		pkg, _ := w.Import("", cursor.fileDir, &PkgConfig{Cursor: cursor, Lenient: lenient})
+		if assert.NotNil(t, pkg, "pkg") {
+			// new.go isn't part of the package's build yet
+			assert.Equal(t, lenient, pkg.Scope().Lookup("New2") != nil, fmt.Sprintf("lenient=%v", lenient))
+		}
+	}
+}
@@ -39,6 +39,7 @@ func (m *mDoc) findCode(packages []string) []*Doc {
 		}()
 	}
 	w := NewPkgWalker(&build.Default, m.FindDef, m.FindUse, m.FindInfo)
+	w.sig = m.sig
 	cursor := &FileCursor{
 		src:       m.Src,
 		cursorPos: m.Offset,
@@ -68,6 +69,9 @@ func (m *mDoc) findCode(packages []string) []*Doc {
 			}
 		}
 		pkg, err := w.Import("", pkgName, conf)
+		if err == cancelledErr || w.sig.cancelled() {
+			return res
+		}
 		if pkg == nil {
 			log.Printf("pkgName: %v, file: %v, dir: %v\n", pkgName, cursor.fileName, cursor.fileDir)
 			log.Fatalln("error import path", err)
@@ -83,6 +87,11 @@ func (m *mDoc) findCode(packages []string) []*Doc {
 func simpleType(src string) string {
 	re, _ := regexp.Compile("[\\w\\./]+")
 	return re.ReplaceAllStringFunc(src, func(s string) string {
+		// keep the dots of variadic parameters e.g. `...pkg.T`
+		dots := ""
+		if strings.HasPrefix(s, "...") {
+			dots, s = "...", s[3:]
+		}
 		r := s
 		if i := strings.LastIndex(s, "/"); i != -1 {
 			r = s[i+1:]
@@ -90,7 +99,7 @@ func simpleType(src string) string {
 		if strings.Count(r, ".") > 1 {
 			r = r[strings.Index(r, ".")+1:]
 		}
-		return r
+		return dots + r
 	})
 }
 
@@ -112,6 +121,9 @@ type PkgConfig struct {
 	Files            map[string]*ast.File
 	TestFiles        map[string]*ast.File
 	XTestFiles       map[string]*ast.File
+	// Lenient type-checks the package the way it's being edited: the cursor file is included even if it's not part
+	// of the package's build, e.g. it's new or excluded by build tags, and imported packages are used despite their errors
+	Lenient bool
 }
 
 func NewPkgWalker(context *build.Context, findDef, findUse, findInfo bool) *PkgWalker {
@@ -139,6 +151,9 @@ type PkgWalker struct {
 	findDef  bool
 	findUse  bool
 	findInfo bool
+
+	// sig is checked before each package is imported so that cancelled requests stop early
+	sig *cancelSignal
 }
 
 func contains(list []string, s string) bool {
@@ -162,6 +177,10 @@ func (w *PkgWalker) Import(parentDir string, name string, conf *PkgConfig) (pkg
 		}
 	}()*/
 
+	if w.sig.cancelled() {
+		return nil, cancelledErr
+	}
+
 	if strings.HasPrefix(name, ".") && parentDir != "" {
 		name = filepath.Join(parentDir, name)
 	}
@@ -244,6 +263,9 @@ func (w *PkgWalker) Import(parentDir string, name string, conf *PkgConfig) (pkg
 			} else {
 				cursor.pos = token.Pos(w.fset.File(f.Pos()).Base()) + token.Pos(cursor.cursorPos)
 				cursor.fileDir = bp.Dir
+				if conf.Lenient {
+					files = append(files, f)
+				}
 			}
 		}
 		return
@@ -271,7 +293,19 @@ func (w *PkgWalker) Import(parentDir string, name string, conf *PkgConfig) (pkg
 					return
 				}
 			}
-			return w.Import(bp.Dir, name, &PkgConfig{IgnoreFuncBodies: true, AllowBinary: true, WithTestFiles: false})
+			pkg, err = w.Import(bp.Dir, name, &PkgConfig{IgnoreFuncBodies: true, AllowBinary: true, WithTestFiles: false, Lenient: conf.Lenient})
+			// the checker reports errors in the imported package's source, but its declarations are still usable
+			if pkg != nil && conf.Lenient {
+				err = nil
+			}
+			// binary-only packages have no source to check, but their export data can still be read
+			if pkg == nil && conf.AllowBinary && !w.isBinaryPkg(name) {
+				if p, _ := gcimporter.Import(imports, name); p != nil && p.Complete() {
+					w.gcimporter[name] = p
+					pkg, err = p, nil
+				}
+			}
+			return
 		},
 		Error: func(err error) {
 			if typeVerbose {
@@ -402,6 +436,7 @@ func (w *PkgWalker) LookupImport(pkg *types.Package, pkgInfo *types.Info, cursor
 			Fn:   fpos.Filename,
 			Row:  fpos.Line - 1,
 			Col:  fpos.Column - 1,
+			Func: w.enclosingFunc(token.Pos(pos)),
 		})
 		if typeVerbose {
 			log.Println(fpos)
@@ -523,13 +558,9 @@ func (w *PkgWalker) lookupNamedMethod(named *types.Named, name string) (types.Ob
 	return nil, nil
 }
 
-func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, cursor *FileCursor) []*Doc {
-	var cursorObj types.Object
-	var cursorSelection *types.Selection
-	var cursorObjIsDef bool
+// LookupCursorObject returns the object under the cursor and, if it was selected (x.f), its selection
+func (w *PkgWalker) LookupCursorObject(pkgInfo *types.Info, cursor *FileCursor) (cursorObj types.Object, cursorSelection *types.Selection) {
 	//lookup defs
-
-	_ = cursorObjIsDef
 	if cursorObj == nil {
 		for sel, obj := range pkgInfo.Selections {
 			if cursor.pos >= sel.Sel.Pos() && cursor.pos <= sel.Sel.End() {
@@ -543,12 +574,10 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 		for id, obj := range pkgInfo.Defs {
 			if cursor.pos >= id.Pos() && cursor.pos <= id.End() {
 				cursorObj = obj
-				cursorObjIsDef = true
 				break
 			}
 		}
 	}
-	_ = cursorSelection
 	if cursorObj == nil {
 		for id, obj := range pkgInfo.Uses {
 			if cursor.pos >= id.Pos() && cursor.pos <= id.End() {
@@ -557,6 +586,11 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 			}
 		}
 	}
+	return
+}
+
+func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, cursor *FileCursor) []*Doc {
+	cursorObj, cursorSelection := w.LookupCursorObject(pkgInfo, cursor)
 	if cursorObj == nil {
 		return []*Doc{}
 	}
@@ -596,8 +630,9 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 		}
 	}
 
+	// export data carries no positions, so the declarations of packages imported from it are looked up in their source
 	if cursorPkg != nil && cursorPkg != pkg &&
-		kind != ObjPkgName && w.isBinaryPkg(cursorPkg.Path()) {
+		kind != ObjPkgName && (w.isBinaryPkg(cursorPkg.Path()) || !cursorPos.IsValid()) {
 		conf := &PkgConfig{
 			IgnoreFuncBodies: true,
 			AllowBinary:      true,
@@ -626,13 +661,8 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 						}
 					}
 				}
-			} else {
-				for _, obj := range conf.Info.Defs {
-					if obj != nil && obj.String() == cursorObj.String() {
-						cursorPos = obj.Pos()
-						break
-					}
-				}
+			} else if pos := lookupSourceDecl(pkg, cursorObj); pos.IsValid() {
+				cursorPos = pos
 			}
 		}
 		if kind == ObjField || cursorIsInterfaceMethod {
@@ -648,7 +678,7 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 	if w.findDef {
 		fpos := w.fset.Position(cursorPos)
 
-		ret = append(ret, &Doc{
+		d := &Doc{
 			Pkg:  cursorObj.Pkg().Name(),
 			Src:  "",
 			Name: cursorObj.Name(),
@@ -656,7 +686,13 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 			Fn:   fpos.Filename,
 			Row:  fpos.Line - 1,
 			Col:  fpos.Column - 1,
-		})
+		}
+		// the source of the package couldn't be found, so the client is given a declaration to show instead
+		if !fpos.IsValid() && kind != ObjPkgName {
+			d.Src = declStub(cursorObj)
+			d.Row, d.Col = 0, 0
+		}
+		ret = append(ret, d)
 		if typeVerbose {
 			log.Println(fpos)
 		}
@@ -730,6 +766,7 @@ func (w *PkgWalker) LookupObjects(pkg *types.Package, pkgInfo *types.Info, curso
 			Fn:   fpos.Filename,
 			Row:  fpos.Line - 1,
 			Col:  fpos.Column - 1,
+			Func: w.enclosingFunc(token.Pos(pos)),
 		})
 		if typeVerbose {
 			log.Println(fpos)
@@ -839,7 +876,7 @@ var builtinInfoMap = map[string]string{
 	"delete":  "func delete(m map[Type]Type1, key Type)",
 	"len":     "func len(v Type) int",
 	"cap":     "func cap(v Type) int",
-	"make":    "func make(Type, size IntegerType) Type",
+	"make":    "func make(t Type, size ...IntegerType) Type",
 	"new":     "func new(Type) *Type",
 	"complex": "func complex(r, i FloatType) ComplexType",
 	"real":    "func real(c ComplexType) FloatType",
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/token"
 )
 
 type mFmt struct {
@@ -10,18 +12,103 @@ type mFmt struct {
 	Src       string
 	TabIndent bool
 	TabWidth  int
+	// Edits returns the changes as a list of edits against Src instead of the whole formatted source
+	Edits bool
+	// if End is greater than Start, only the source between these byte offsets is formatted
+	Start int
+	End   int
+	// Simplify applies the gofmt -s simplifications
+	Simplify bool
+	// Rewrite is a list of gofmt -r rules of the form `pattern -> replacement`, applied in order
+	Rewrite []string
+
+	impPolicy
 }
 
 func (m *mFmt) Call() (interface{}, string) {
 	res := M{}
+	// the number of times each rewrite rule or simplification changed the source
+	rewrites := map[string]int{}
+	rewrite, err := m.rewriter(rewrites)
+	if err != nil {
+		return res, err.Error()
+	}
+
+	if m.End > m.Start {
+		orig, err := readSrc(m.Fn, m.Src)
+		if err != nil {
+			return res, err.Error()
+		}
+
+		src, err := fmtRange(orig, m.Start, m.End, m.TabIndent, m.TabWidth, rewrite, m.impPolicy)
+		if err == nil {
+			res["rewrites"] = rewrites
+			if m.Edits {
+				res["edits"] = diffEdits(orig, src)
+			} else {
+				res["src"] = src
+			}
+		}
+		return res, errStr(err)
+	}
+
 	fset, af, err := parseAstFile(m.Fn, m.Src, parser.ParseComments)
 	if err == nil {
-		ast.SortImports(fset, af)
-		res["src"], err = printSrc(fset, af, m.TabIndent, m.TabWidth)
+		af = rewrite(fset, af)
+		res["rewrites"] = rewrites
+
+		// the import policy does its own sorting, and unlike SortImports, keeps comments with their imports
+		if m.ImportGroups == "" {
+			ast.SortImports(fset, af)
+		}
+		var src string
+		if src, err = printSrc(fset, af, m.TabIndent, m.TabWidth); err == nil {
+			src, err = m.impPolicy.apply(src, m.TabIndent, m.TabWidth)
+		}
+		if err == nil && m.Edits {
+			var orig string
+			if orig, err = readSrc(m.Fn, m.Src); err == nil {
+				res["edits"] = diffEdits(orig, src)
+			}
+		} else {
+			res["src"] = src
+		}
 	}
 	return res, errStr(err)
 }
 
+// rewriter returns a function that applies the rewrite rules, then the simplifications, to a file.
+// the number of times each of them changed the file is added to rewrites
+func (m *mFmt) rewriter(rewrites map[string]int) (func(fset *token.FileSet, af *ast.File) *ast.File, error) {
+	type rule struct {
+		s                string
+		pattern, replace ast.Expr
+	}
+	rules := []rule{}
+	for _, s := range m.Rewrite {
+		pattern, replace, err := parseRewriteRule(s)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite rule `%s`: %s", s, err)
+		}
+		rules = append(rules, rule{s, pattern, replace})
+	}
+
+	return func(fset *token.FileSet, af *ast.File) *ast.File {
+		for _, r := range rules {
+			var n int
+			if af, n = rewriteFile(fset, r.pattern, r.replace, af); n > 0 {
+				rewrites[r.s] += n
+			}
+		}
+		if m.Simplify {
+			for s, n := range simplifyFile(af) {
+				rewrites[s] += n
+			}
+		}
+		return af
+	}, nil
+}
+
 func init() {
 	registry.Register("fmt", func(b *Broker) Caller {
 		return &mFmt{
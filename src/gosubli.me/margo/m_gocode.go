@@ -2,10 +2,13 @@ package main
 
 import (
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
 	"gosubli.me/something-borrowed/gocode"
+	"gosubli.me/something-borrowed/types"
 	"io/ioutil"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -20,28 +23,18 @@ type mGocode struct {
 	Fn            string
 	Src           string
 	Pos           int
+	// Engine selects how completions and calltips are found:
+	// `gocode` (the default) uses gocode's own type inference and
+	// `types` type-checks the package, falling back to gocode if the package or the cursor context can't be checked
+	Engine string
+	// Snippets adds snippets to the candidates found by the gocode engine
+	Snippets bool
+	// Fuzzy matches the names found by either engine with a fuzzy, camel-case aware match instead of by prefix,
+	// and sorts the candidates by their score
+	Fuzzy bool
 
 	calltip bool
-}
-
-type calltipVisitor struct {
-	offset int
-	fset   *token.FileSet
-	x      *ast.CallExpr
-}
-
-func (v *calltipVisitor) Visit(node ast.Node) (w ast.Visitor) {
-	if node != nil {
-		if x, ok := node.(*ast.CallExpr); ok {
-			a := v.fset.Position(node.Pos())
-			b := v.fset.Position(node.End())
-
-			if (a.IsValid() && v.offset >= a.Offset) && (!b.IsValid() || v.offset <= b.Offset) {
-				v.x = x
-			}
-		}
-	}
-	return v
+	cancelable
 }
 
 func (m *mGocode) Call() (interface{}, string) {
@@ -68,15 +61,17 @@ func (m *mGocode) Call() (interface{}, string) {
 
 	res := struct {
 		Candidates []gocode.MargoCandidate
+		// Signature is only set by gocode_calltip
+		Signature *Signature `json:",omitempty"`
 	}{}
 
 	if m.calltip {
-		res.Candidates = m.calltips(src, fn, pos)
+		res.Candidates, res.Signature = m.calltips(src, fn, pos)
 	} else {
 		res.Candidates = m.completions(src, fn, pos)
 	}
 
-	if m.Autoinst && len(res.Candidates) == 0 {
+	if m.Autoinst && len(res.Candidates) == 0 && !m.sig.cancelled() {
 		autoInstall(AutoInstOptions{
 			Src:           m.Src,
 			Env:           m.Env,
@@ -88,70 +83,243 @@ func (m *mGocode) Call() (interface{}, string) {
 }
 
 func (g *mGocode) completions(src []byte, fn string, pos int) []gocode.MargoCandidate {
+	if g.Engine == "types" {
+		if cl, ok := g.typesComplete(src, fn, pos); ok {
+			return cl
+		}
+	}
+
+	return gocode.Margo.Complete(g.gocodeConfig(), src, fn, pos)
+}
+
+func (g *mGocode) gocodeConfig() gocode.MargoConfig {
 	c := gocode.MargoConfig{}
 	c.InstallSuffix = g.InstallSuffix
 	c.Builtins = g.Builtins
+	c.Snippets = g.Snippets
+	c.Fuzzy = g.Fuzzy
 	c.GOROOT, c.GOPATHS = envRootList(g.Env)
-	return gocode.Margo.Complete(c, src, fn, pos)
+	c.Cancel = g.sig.done()
+	return c
 }
 
-func (m *mGocode) calltips(src []byte, fn string, offset int) []gocode.MargoCandidate {
-	id, fset, af := identAtOffset(src, offset)
+// calltips returns the signature of the function called at offset,
+// and the function as a candidate for older clients that only read the candidates.
+// like completions, the package is only type-checked if the types engine is selected
+func (m *mGocode) calltips(src []byte, fn string, offset int) ([]gocode.MargoCandidate, *Signature) {
+	var sig *Signature
+	if m.Engine == "types" {
+		sig = m.typesSignature(src, fn, offset)
+	}
+	if sig == nil {
+		sig = m.gocodeSignature(src, fn, offset)
+	}
+	if sig == nil {
+		return []gocode.MargoCandidate{}, nil
+	}
+
+	c := gocode.MargoCandidate{
+		Name:  sig.Name,
+		Type:  sig.Type(),
+		Class: "func",
+	}
+	return []gocode.MargoCandidate{c}, sig
+}
+
+// gocodeSignature finds the signature of the function called at offset by completing its name with gocode or,
+// if it's not called by name e.g. it's the result of a call, by inferring the type of the callee.
+// the doc comment is looked up in the package index, see gocodeDoc
+func (m *mGocode) gocodeSignature(src []byte, fn string, offset int) *Signature {
+	fset := token.NewFileSet()
+	af, _ := parser.ParseFile(fset, "<stdin>", src, 0)
+	if af == nil {
+		return nil
+	}
+	tf := fset.File(af.Pos())
+	if offset > tf.Size() {
+		return nil
+	}
+
+	call := enclosingCall(af, tf.Pos(offset))
+	if call == nil {
+		return nil
+	}
+
+	var sig *Signature
+	var id *ast.Ident
+	switch x := call.Fun.(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	}
 	if id != nil {
-		cp := fset.Position(id.End())
-		if cp.IsValid() {
-			line := offsetLine(fset, af, offset)
-			cr := cp.Offset
-			cl := m.completions(src, fn, cr)
-
-			if (cp.Line == line || line == 0) && len(cl) > 0 {
-				for i, c := range cl {
-					if strings.EqualFold(id.Name, c.Name) {
-						return cl[i : i+1]
-					}
+		for _, c := range m.completions(src, fn, tf.Offset(id.End())) {
+			if strings.EqualFold(id.Name, c.Name) {
+				if sig = astSignature(c.Name, c.Type); sig != nil {
+					break
 				}
 			}
 		}
 	}
+	if sig == nil {
+		sig = astSignature(calleeName(call.Fun), m.exprType(src, fn, tf, call.Fun))
+	}
+	if sig == nil {
+		return nil
+	}
 
-	return []gocode.MargoCandidate{}
+	sig.setActive(argIndex(src, tf.Offset(call.Lparen), offset))
+	sig.Doc = m.gocodeDoc(src, fn, tf, af, call.Fun)
+	return sig
 }
 
-func identAtOffset(src []byte, offset int) (id *ast.Ident, fset *token.FileSet, af *ast.File) {
-	fset = token.NewFileSet()
-	af, _ = parser.ParseFile(fset, "<stdin>", src, 0)
+// exprType returns the type of x, as inferred by gocode at the end of x
+func (m *mGocode) exprType(src []byte, fn string, tf *token.File, x ast.Expr) string {
+	end := tf.Offset(x.End())
+	return gocode.Margo.ExprType(m.gocodeConfig(), src, fn, end, string(src[tf.Offset(x.Pos()):end]))
+}
 
-	if af == nil {
-		return
+// gocodeDoc returns the doc comment of the function or method called by x.
+// gocode doesn't know where things are declared so the function is looked up by name in the package index:
+// functions called via a package name in the package it names, the others in the file's own package,
+// and methods by the name of the receiver's type, as inferred by gocode, in the file's package and the packages it imports.
+// it's empty if x isn't a name e.g. it's the result of a call, or the method is declared by an interface
+func (m *mGocode) gocodeDoc(src []byte, fn string, tf *token.File, af *ast.File, x ast.Expr) string {
+	for {
+		px, ok := x.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		x = px.X
 	}
 
-	vis := &calltipVisitor{
-		offset: offset,
-		fset:   fset,
+	imports := map[string]string{}
+	ipaths := []string{}
+	for _, spec := range af.Imports {
+		ipath := unquote(spec.Path.Value)
+		name := path.Base(ipath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = ipath
+		ipaths = append(ipaths, ipath)
 	}
-	ast.Walk(vis, af)
 
-	if vis.x != nil && vis.x.Fun != nil {
-		switch v := vis.x.Fun.(type) {
-		case *ast.Ident:
-			id = v
-		case *ast.SelectorExpr:
-			id = v.Sel
+	dir := filepath.Dir(fn)
+	ctx := buildContext(m.Env)
+	importDir := func(ipath string) string {
+		if bp, err := ctx.Import(ipath, dir, build.FindOnly); err == nil {
+			return bp.Dir
 		}
+		return ""
 	}
-	return
-}
 
-func offsetLine(fset *token.FileSet, af *ast.File, offset int) (line int) {
-	defer func() {
-		if err := recover(); err != nil {
-			line = 0
+	name, recv := "", ""
+	dirs := []string{dir}
+	switch x := x.(type) {
+	case *ast.Ident:
+		// funcs assigned to vars, e.g. locals, aren't looked up
+		if x.Obj != nil && x.Obj.Kind != ast.Fun {
+			return ""
+		}
+		name = x.Name
+	case *ast.SelectorExpr:
+		name = x.Sel.Name
+		if id, ok := x.X.(*ast.Ident); ok && id.Obj == nil && imports[id.Name] != "" {
+			dirs = []string{importDir(imports[id.Name])}
+			break
 		}
-	}()
-	return fset.File(af.Pos()).Position(token.Pos(offset)).Line
+
+		recv = strings.TrimPrefix(m.exprType(src, fn, tf, x.X), "*")
+		if i := strings.LastIndex(recv, "."); i >= 0 {
+			dirs = []string{importDir(imports[recv[:i]])}
+			recv = recv[i+1:]
+		} else {
+			for _, ipath := range ipaths {
+				dirs = append(dirs, importDir(ipath))
+			}
+		}
+		if recv == "" {
+			return ""
+		}
+	default:
+		return ""
+	}
+
+	// the file itself may not be saved
+	for _, decl := range af.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Name.Name != name {
+			continue
+		}
+		fdRecv := ""
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			fdRecv = strings.TrimPrefix(types.ExprString(fd.Recv.List[0].Type), "*")
+		}
+		if fdRecv == recv {
+			return declDoc(fn, src, tf.Offset(fd.Name.Pos()))
+		}
+	}
+
+	isTest := strings.HasSuffix(strings.ToLower(fn), "_test.go")
+	declFn, row, col := "", 0, 0
+	for _, root := range dirs {
+		if root == "" || declFn != "" {
+			continue
+		}
+		pkgIdx.walk(m.Env, root, false, m.sig, func(dir string, d *idxDir) {
+			own := dir == filepath.Dir(fn)
+			for _, nm := range d.fileNames() {
+				f := d.Files[nm]
+				p := filepath.Join(dir, nm)
+				if declFn != "" || p == fn || f.Ignore || (own && f.Pkg != af.Name.Name) {
+					continue
+				}
+				if (!own || !isTest) && strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+					continue
+				}
+				for _, decl := range f.Decls {
+					if decl.Kind == "func" && decl.Name == name && strings.TrimPrefix(decl.Recv, "*") == recv {
+						declFn, row, col = p, decl.Row, decl.Col
+						break
+					}
+				}
+			}
+		})
+	}
+	if declFn == "" {
+		return ""
+	}
+
+	s, err := ioutil.ReadFile(declFn)
+	if err != nil {
+		return ""
+	}
+	lines := lineOffsets(string(s))
+	if row >= len(lines) {
+		return ""
+	}
+	return declDoc(declFn, s, lines[row]+col)
+}
+
+// mGocodeAccepted tells gocode that the user inserted the candidate Name so that it's ranked higher in later completions
+type mGocodeAccepted struct {
+	Name string
+}
+
+func (m *mGocodeAccepted) Call() (interface{}, string) {
+	if m.Name != "" {
+		gocode.Margo.Accepted(m.Name)
+	}
+	return M{}, ""
 }
 
 func init() {
+	registry.Register("gocode_accepted", func(b *Broker) Caller {
+		return &mGocodeAccepted{}
+	})
+
 	registry.Register("gocode_complete", func(b *Broker) Caller {
 		return &mGocode{}
 	})
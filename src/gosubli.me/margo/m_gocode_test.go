@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gosubli.me/something-borrowed/gocode"
+)
+
+func TestTypesComplete(t *testing.T) {
+	wd, _ := os.Getwd()
+	fn := filepath.Join(wd, "testing", "simple.go")
+	b, _ := ioutil.ReadFile(fn)
+	src := strings.Replace(string(b), "\tt.value = \"test\"\n", "\tfunc() {\n\t\tt\n\t\tt.\n\t}()\n", 1)
+
+	g := &mGocode{Env: map[string]string{}}
+	pos := strings.Index(src, "t.\n") + 2
+	cl, ok := g.typesComplete([]byte(src), fn, pos)
+	assert.Equal(t, true, ok, "selector")
+	assert.Equal(t, []gocode.MargoCandidate{{Name: "value", Type: "string", Class: "var", Score: 20}}, cl, "selector")
+
+	// t is a local, the exact match of the partial identifier
+	pos = strings.Index(src, "\tt\n") + 2
+	cl, ok = g.typesComplete([]byte(src), fn, pos)
+	assert.Equal(t, true, ok, "scope")
+	assert.Equal(t, []gocode.MargoCandidate{{Name: "t", Type: "*a", Class: "var", Score: 62}}, cl, "scope")
+
+	// `vl` only matches `value` with fuzzy matching
+	src = strings.Replace(src, "t.\n", "t.vl\n", 1)
+	pos = strings.Index(src, "t.vl\n") + 4
+	cl, ok = g.typesComplete([]byte(src), fn, pos)
+	assert.Equal(t, true, ok, "prefix")
+	assert.Equal(t, 0, len(cl), "prefix")
+
+	g.Fuzzy = true
+	cl, ok = g.typesComplete([]byte(src), fn, pos)
+	assert.Equal(t, true, ok, "fuzzy")
+	if assert.Equal(t, 1, len(cl), "fuzzy") {
+		assert.Equal(t, "value", cl[0].Name, "fuzzy")
+	}
+}
+
+func TestTypesSignature(t *testing.T) {
+	wd, _ := os.Getwd()
+	fn := filepath.Join(wd, "testing", "simple.go")
+	b, _ := ioutil.ReadFile(fn)
+	src := strings.Replace(string(b), "\tt.value = \"test\"\n", "\tf := func(a int, b ...string) {}\n\tf(1, \"x\", )\n", 1)
+
+	g := &mGocode{Env: map[string]string{}}
+	pos := strings.Index(src, "\"x\", )") + 5
+	sig := g.typesSignature([]byte(src), fn, pos)
+	assert.Equal(t, &Signature{
+		Name:     "f",
+		Params:   []SignatureParam{{Name: "a", Type: "int"}, {Name: "b", Type: "...string"}},
+		Results:  []SignatureParam{},
+		Variadic: true,
+		Active:   1,
+	}, sig)
+}
+
+func TestArgIndex(t *testing.T) {
+	tests := []struct {
+		src string
+		arg int
+	}{
+		{"f(|)", 0},
+		{"f(a, |)", 1},
+		{"f(a, \"x,y\", g(1, 2), /* , */ |", 3},
+		{"f(a, []int{1, 2}|)", 1},
+		{"f(a)|", -1},
+		{"f|(a)", -1},
+	}
+	for _, test := range tests {
+		pos := strings.Index(test.src, "|")
+		src := strings.Replace(test.src, "|", "", 1)
+		assert.Equal(t, test.arg, argIndex([]byte(src), strings.Index(src, "("), pos), test.src)
+	}
+}
+
+func TestGocodeUninstalledPkg(t *testing.T) {
+	// ex/a has no archive, so its declarations are read from the source found in the configured GOPATH
+	src := "package b\n\nimport \"ex/a\"\n\nfunc f() {\n\ta.\n}\n"
+	g := &mGocode{
+		Env: fixtureEnv(),
+		Fn:  fixtureFile("ex/b/f.go"),
+		Src: src,
+		Pos: strings.Index(src, "a.\n") + 2,
+	}
+	cl := g.completions([]byte(src), g.Fn, g.Pos)
+
+	names := []string{}
+	for _, c := range cl {
+		names = append(names, c.Class+" "+c.Name)
+	}
+	assert.Equal(t, []string{"func New", "type Namer", "type T"}, names)
+}
+
+func TestGocodeSnippets(t *testing.T) {
+	// the types of another package are qualified by the name it's imported as
+	src := "package b\n\nimport sh \"ex/shapes\"\n\nfunc f() {\n\tsh.\n}\n"
+	g := &mGocode{
+		Env:      fixtureEnv(),
+		Fn:       fixtureFile("ex/b/f.go"),
+		Src:      src,
+		Pos:      strings.Index(src, "sh.\n") + 3,
+		Snippets: true,
+	}
+	cl := g.completions([]byte(src), g.Fn, g.Pos)
+
+	snippets := map[string]string{}
+	for _, c := range cl {
+		if c.Snippet != "" {
+			snippets[c.Name+" "+c.Type] = c.Snippet
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"Resize func(Shape, Factor)": "Resize(${1:sh.Shape}, ${2:sh.Factor})",
+		"Box struct literal":         "Box{\n\tMin: ${1:sh.Point},\n\tMax: ${2:sh.Point},\n}$0",
+		"Point struct literal":       "Point{\n\tX: ${1:float64},\n\tY: ${2:float64},\n}$0",
+		"Shape method stubs": "func (${1:r} ${2:*T}) Area() float64 {\n\tpanic(\"not implemented\")\n}\n\n" +
+			"func (${1:r} ${2:*T}) Scale(f sh.Factor) sh.Shape {\n\tpanic(\"not implemented\")\n}$0",
+	}, snippets)
+}
+
+func TestGocodeCalltip(t *testing.T) {
+	// without the types engine, the package isn't type-checked so the signature comes from gocode
+	// and the doc from the declaration found in the package index
+	src := "package b\n\nimport \"ex/a\"\n\nfunc f() {\n\ta.New(1)\n}\n"
+	g := &mGocode{
+		Env: fixtureEnv(),
+		Fn:  fixtureFile("ex/b/f.go"),
+	}
+	cl, sig := g.calltips([]byte(src), g.Fn, strings.Index(src, "(1)")+1)
+	if assert.NotNil(t, sig) {
+		assert.Equal(t, "New", sig.Name)
+		assert.Equal(t, 0, sig.Active)
+		assert.Equal(t, "New returns a T for v", sig.Doc)
+	}
+	assert.Equal(t, 1, len(cl))
+}
+
+func TestGocodeCalltipCallees(t *testing.T) {
+	src := `package b
+
+import "ex/a"
+
+// mk makes a func that adds n
+func mk(n int) func(x, y int) int {
+	return nil
+}
+
+func f() {
+	t := a.New(1)
+	t.Name()
+	mk(1)(2, 3)
+	(mk)(4)
+}
+`
+	// each signature is described as `name(params) doc`
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"t.Name(|)", "Name() Name returns the name of t"},
+		{"mk(1)(2, |3)", "mk(1)(x int, y int) "},
+		{"(mk)(|4)", "mk(n int) mk makes a func that adds n"},
+	}
+
+	g := &mGocode{
+		Env: fixtureEnv(),
+		Fn:  fixtureFile("ex/b/f.go"),
+	}
+	for _, tt := range tests {
+		pos := strings.Index(src, strings.Replace(tt.at, "|", "", 1)) + strings.Index(tt.at, "|")
+		_, sig := g.calltips([]byte(src), g.Fn, pos)
+		if !assert.NotNil(t, sig, tt.at) {
+			continue
+		}
+		params := []string{}
+		for _, p := range sig.Params {
+			params = append(params, p.Name+" "+p.Type)
+		}
+		got := fmt.Sprintf("%s(%s) %s", sig.Name, strings.Join(params, ", "), sig.Doc)
+		assert.Equal(t, tt.want, got, tt.at)
+	}
+}
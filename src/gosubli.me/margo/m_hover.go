@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"go/doc"
+	"go/token"
+	"io/ioutil"
+	"strings"
+
+	"gosubli.me/something-borrowed/exact"
+	"gosubli.me/something-borrowed/types"
+)
+
+// Hover describes the object under the cursor
+type Hover struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Type is the type of the object, or the underlying type if it's a type.
+	// types from other packages are qualified by their import path
+	Type string `json:"type"`
+	// Value is the exact value of a constant
+	Value string `json:"value,omitempty"`
+	// Methods and PtrMethods are the sizes of the method sets of a type T and of *T
+	Methods    int `json:"methods,omitempty"`
+	PtrMethods int `json:"ptrMethods,omitempty"`
+	// Pkg is the import path of the package that declares the object, or of the package it names
+	Pkg string `json:"pkg"`
+	Doc string `json:"doc"`
+	// Fn, Row and Col are the position of the declaration, if it's known
+	Fn  string `json:"fn"`
+	Row int    `json:"row"`
+	Col int    `json:"col"`
+}
+
+type mHover struct {
+	Fn     string
+	Src    string
+	Env    map[string]string
+	Offset int
+	// DocWidth is the width the doc comment is wrapped at
+	DocWidth int
+
+	cancelable
+}
+
+func (m *mHover) Call() (interface{}, string) {
+	if m.Src == "" {
+		s, err := ioutil.ReadFile(m.Fn)
+		if err != nil {
+			return nil, err.Error()
+		}
+		m.Src = string(s)
+	}
+	if m.Offset < 0 || m.Offset > len(m.Src) {
+		return nil, "Invalid offset"
+	}
+
+	src := []byte(m.Src)
+	tf, ok := typesCheck(m.Env, m.sig, src, m.Fn, m.Offset)
+	if m.sig.cancelled() {
+		return nil, errCancelled
+	}
+	if !ok {
+		return nil, "cannot type-check the package of " + m.Fn
+	}
+
+	obj, _ := tf.w.LookupCursorObject(tf.info, &FileCursor{pos: tf.pos})
+	if obj == nil {
+		return nil, "no object at the cursor"
+	}
+	kind, err := parserObjKind(obj)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	h := &Hover{
+		Name: obj.Name(),
+		Kind: kind.String(),
+	}
+	if obj.Pkg() != nil {
+		h.Pkg = obj.Pkg().Path()
+	}
+
+	switch o := obj.(type) {
+	case *types.PkgName:
+		h.Pkg = o.Imported().Path()
+	case *types.Builtin:
+		h.Type = strings.TrimPrefix(builtinInfoMap[o.Name()], "func "+o.Name())
+		if h.Type != "" {
+			h.Type = "func" + h.Type
+		}
+	case *types.TypeName:
+		T := o.Type()
+		h.Type = types.TypeString(tf.pkg, T.Underlying())
+		h.Methods = types.NewMethodSet(T).Len()
+		switch T.Underlying().(type) {
+		case *types.Pointer, *types.Interface:
+		default:
+			h.PtrMethods = types.NewMethodSet(types.NewPointer(T)).Len()
+		}
+	case *types.Const:
+		h.Type = types.TypeString(tf.pkg, o.Type())
+		if v := o.Val(); v != nil && v.Kind() != exact.Unknown {
+			h.Value = v.String()
+		}
+	default:
+		h.Type = types.TypeString(tf.pkg, o.Type())
+	}
+
+	if p := m.declPos(tf, obj); p.IsValid() {
+		h.Fn = p.Filename
+		h.Row = p.Line - 1
+		h.Col = p.Column - 1
+
+		var declSrc interface{}
+		if p.Filename == tf.w.fset.File(tf.af.Pos()).Name() {
+			declSrc = src
+		}
+		if s := declDoc(p.Filename, declSrc, p.Offset); s != "" {
+			var buf bytes.Buffer
+			doc.ToText(&buf, s, "", "\t", m.DocWidth)
+			h.Doc = strings.TrimSpace(buf.String())
+		}
+	}
+	return h, ""
+}
+
+// declPos returns the position of obj's declaration.
+// objects imported from an archive have no position, so their package is imported from source to find it
+func (m *mHover) declPos(tf typesFile, obj types.Object) token.Position {
+	if obj.Pos().IsValid() || obj.Pkg() == nil {
+		return tf.w.fset.Position(obj.Pos())
+	}
+
+	conf := &PkgConfig{
+		IgnoreFuncBodies: true,
+		AllowBinary:      true,
+	}
+	if pkg, _ := tf.w.Import("", obj.Pkg().Path(), conf); pkg != nil {
+		return tf.w.fset.Position(lookupSourceDecl(pkg, obj))
+	}
+	return token.Position{}
+}
+
+func init() {
+	registry.Register("hover", func(_ *Broker) Caller {
+		return &mHover{
+			Env:      map[string]string{},
+			DocWidth: 80,
+		}
+	})
+}
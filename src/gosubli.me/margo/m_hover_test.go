@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHover(t *testing.T) {
+	wd, _ := os.Getwd()
+	fn := filepath.Join(wd, "testing", "simple.go")
+	src := "package testing\n\n// a is a test type\ntype a struct {\n\tvalue string\n}\n\nconst n = 1 << 62 / 3\n\nfunc (*a) f() int { return n }\n"
+
+	hover := func(sel string) *Hover {
+		m := &mHover{Fn: fn, Src: src, Env: map[string]string{}, Offset: strings.Index(src, sel), DocWidth: 80}
+		res, err := m.Call()
+		assert.Equal(t, "", err, sel)
+		h, _ := res.(*Hover)
+		return h
+	}
+
+	h := hover("a struct")
+	if assert.NotNil(t, h, "type") {
+		assert.Equal(t, "struct", h.Kind, "Kind")
+		assert.Equal(t, "struct{value string}", h.Type, "Type")
+		assert.Equal(t, 0, h.Methods, "Methods")
+		assert.Equal(t, 1, h.PtrMethods, "PtrMethods")
+		assert.Equal(t, "a is a test type", h.Doc, "Doc")
+		assert.Equal(t, 3, h.Row, "Row")
+	}
+
+	h = hover("n }")
+	if assert.NotNil(t, h, "const") {
+		assert.Equal(t, "const", h.Kind, "Kind")
+		assert.Equal(t, "untyped int", h.Type, "Type")
+		assert.Equal(t, "1537228672809129301", h.Value, "Value")
+	}
+}
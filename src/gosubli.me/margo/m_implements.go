@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gosubli.me/something-borrowed/gcimporter"
+	"gosubli.me/something-borrowed/types"
+)
+
+type mImplements struct {
+	Fn     string
+	Src    interface{}
+	Env    map[string]string
+	Offset int
+
+	cancelable
+}
+
+func (m *mImplements) Call() (interface{}, string) {
+	res := Docs{}
+	ctx := buildContext(m.Env)
+	w := NewPkgWalker(ctx, false, false, false)
+	w.sig = m.sig
+	cursor := &FileCursor{
+		src:       m.Src,
+		cursorPos: m.Offset,
+		fileName:  filepath.Base(m.Fn),
+		fileDir:   filepath.Dir(m.Fn),
+	}
+
+	target, pkgs, err := w.workspacePkgs(cursor, gopathSrcDirs(ctx))
+	if err != nil {
+		return res, err.Error()
+	}
+
+	r := &objRefs{
+		w:      w,
+		target: target,
+		pkgs:   pkgs,
+	}
+	r.resolveTarget()
+	target = r.target
+
+	switch obj := target.(type) {
+	case *types.TypeName:
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			if iface.NumMethods() == 0 {
+				return res, fmt.Sprintf("%s is implemented by all types", obj.Name())
+			}
+			w.loadImplementers(gopathSrcDirs(ctx), iface)
+			for _, impl := range w.implementations(iface) {
+				res = append(res, w.implDoc(impl.obj, impl.name()))
+			}
+		} else {
+			w.loadInterfaces(gopathSrcDirs(ctx))
+			for _, p := range w.loadedPkgs(true) {
+				for _, iobj := range pkgTypeNames(p) {
+					iface, ok := iobj.Type().Underlying().(*types.Interface)
+					if !ok || iface.NumMethods() == 0 || iobj == obj {
+						continue
+					}
+					if p != obj.Pkg() && !iobj.Exported() {
+						continue
+					}
+					if types.Implements(obj.Type(), iface) || types.Implements(types.NewPointer(obj.Type()), iface) {
+						res = append(res, w.implDoc(iobj, iobj.Name()))
+					}
+				}
+			}
+		}
+	case *types.Func:
+		recv := obj.Type().(*types.Signature).Recv()
+		var iface *types.Interface
+		if recv != nil {
+			iface, _ = recv.Type().Underlying().(*types.Interface)
+		}
+		if iface == nil {
+			return res, fmt.Sprintf("%s is not an interface method", obj.Name())
+		}
+		w.loadImplementers(gopathSrcDirs(ctx), iface)
+		for _, impl := range w.implementations(iface) {
+			var t types.Type = impl.obj.Type()
+			if impl.pointer {
+				t = types.NewPointer(t)
+			}
+			if m, _, _ := types.LookupFieldOrMethod(t, false, obj.Pkg(), obj.Name()); m != nil {
+				res = append(res, w.implDoc(m, "("+impl.name()+")."+m.Name()))
+			}
+		}
+	default:
+		return res, fmt.Sprintf("%s is not a type or method", target.Name())
+	}
+
+	if w.sig.cancelled() {
+		return Docs{}, errCancelled
+	}
+
+	// the same package may have been type-checked more than once
+	sort.Sort(res)
+	l := Docs{}
+	for i, d := range res {
+		if i == 0 || *d != *res[i-1] {
+			l = append(l, d)
+		}
+	}
+	return l, ""
+}
+
+func init() {
+	registry.Register("implements", func(_ *Broker) Caller {
+		return &mImplements{
+			Env: map[string]string{},
+		}
+	})
+}
+
+type implementation struct {
+	obj *types.TypeName
+	// pointer is true if only the pointer to the type implements the interface
+	pointer bool
+}
+
+func (i implementation) name() string {
+	if i.pointer {
+		return "*" + i.obj.Name()
+	}
+	return i.obj.Name()
+}
+
+// implementations returns the concrete types in the loaded packages that implement iface
+func (w *PkgWalker) implementations(iface *types.Interface) []implementation {
+	l := []implementation{}
+	for _, p := range w.loadedPkgs(false) {
+		for _, obj := range pkgTypeNames(p) {
+			t := obj.Type()
+			if _, ok := t.Underlying().(*types.Interface); ok {
+				continue
+			}
+			if types.Implements(t, iface) {
+				l = append(l, implementation{obj: obj})
+			} else if types.Implements(types.NewPointer(t), iface) {
+				l = append(l, implementation{obj: obj, pointer: true})
+			}
+		}
+	}
+	return l
+}
+
+// loadImplementers imports the packages in srcDirs that declare a type with methods named like those of iface.
+// they may implement it without importing the package that declares it, so they're not found by workspacePkgs
+func (w *PkgWalker) loadImplementers(srcDirs []string, iface *types.Interface) {
+	methods := make([]string, iface.NumMethods())
+	for i := range methods {
+		methods[i] = iface.Method(i).Name()
+	}
+
+	w.loadIndexed(srcDirs, func(d *idxDir) bool {
+		return d.declaresMethods(methods)
+	})
+}
+
+// loadInterfaces imports the packages in srcDirs that declare interfaces.
+// the type may implement them without either package importing the other, so they're not found by workspacePkgs
+func (w *PkgWalker) loadInterfaces(srcDirs []string) {
+	w.loadIndexed(srcDirs, (*idxDir).declaresInterfaces)
+}
+
+// loadIndexed imports the packages in srcDirs whose index matches, the packages in testdata directories are skipped
+func (w *PkgWalker) loadIndexed(srcDirs []string, match func(d *idxDir) bool) {
+	paths := []string{}
+	for _, srcDir := range srcDirs {
+		pkgIdx.walk(nil, srcDir, true, w.sig, func(dir string, d *idxDir) {
+			p, err := filepath.Rel(srcDir, dir)
+			if err != nil || p == "." {
+				return
+			}
+			p = filepath.ToSlash(p)
+			if contains(strings.Split(p, "/"), "testdata") {
+				return
+			}
+			if match(d) {
+				paths = append(paths, p)
+			}
+		})
+	}
+
+	for _, p := range paths {
+		if w.sig.cancelled() {
+			return
+		}
+		if w.imported[p] == nil {
+			w.Import("", p, &PkgConfig{AllowBinary: true})
+		}
+	}
+}
+
+// loadedPkgs returns the packages imported by w so far, i.e. those in the workspace that were type-checked
+// along with their dependencies. if withStd is true, the standard library packages are imported (from their archives) as well
+func (w *PkgWalker) loadedPkgs(withStd bool) []*types.Package {
+	if withStd {
+		for _, p := range stdPkg {
+			if w.sig.cancelled() {
+				break
+			}
+			if strings.HasPrefix(p, "cmd/") || w.imported[p] != nil || w.gcimporter[p] != nil {
+				continue
+			}
+			if pkg, _ := gcimporter.Import(w.gcimporter, p); pkg != nil && pkg.Complete() {
+				w.gcimporter[p] = pkg
+			}
+		}
+	}
+
+	seen := map[*types.Package]bool{types.Unsafe: true}
+	l := []*types.Package{}
+	add := func(m map[string]*types.Package) {
+		for _, p := range m {
+			if p != nil && p != &w.importing && !seen[p] {
+				seen[p] = true
+				l = append(l, p)
+			}
+		}
+	}
+	add(w.imported)
+	add(w.gcimporter)
+	return l
+}
+
+// pkgTypeNames returns the package-level type declarations of pkg
+func pkgTypeNames(pkg *types.Package) []*types.TypeName {
+	l := []*types.TypeName{}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if obj, ok := scope.Lookup(name).(*types.TypeName); ok {
+			l = append(l, obj)
+		}
+	}
+	return l
+}
+
+func (w *PkgWalker) implDoc(obj types.Object, name string) *Doc {
+	kind, _ := parserObjKind(obj)
+	d := &Doc{
+		Pkg:  obj.Pkg().Name(),
+		Name: name,
+		Kind: kind.String(),
+	}
+	if obj.Pos().IsValid() {
+		tp := w.fset.Position(obj.Pos())
+		d.Fn = tp.Filename
+		d.Row = tp.Line - 1
+		d.Col = tp.Column - 1
+	}
+	return d
+}
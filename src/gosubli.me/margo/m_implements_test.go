@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImplements(t *testing.T) {
+	// each result is described as `file:row:name`
+	tests := []struct {
+		name string
+		fn   string
+		at   string
+		want []string
+	}{
+		{
+			// ex/c doesn't import ex/a
+			name: "interface",
+			at:   "type |Namer",
+			want: []string{"a.go:8:*T", "c.go:3:Named"},
+		},
+		{
+			name: "interface method",
+			at:   "\t|Name() string",
+			want: []string{"a.go:14:(*T).Name", "c.go:5:(Named).Name"},
+		},
+		{
+			name: "type",
+			at:   "type |T struct",
+			want: []string{"a.go:3:Namer"},
+		},
+		{
+			// neither ex/c nor ex/a imports the other
+			name: "type in an unrelated package",
+			fn:   "ex/c/c.go",
+			at:   "type |Named",
+			want: []string{"a.go:3:Namer"},
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.fn == "" {
+			tt.fn = "ex/a/a.go"
+		}
+		m := &mImplements{
+			Fn:     fixtureFile(tt.fn),
+			Env:    fixtureEnv(),
+			Offset: fixtureOffset(t, tt.fn, tt.at),
+		}
+		raw, err := m.Call()
+		assert.Equal(t, "", err, tt.name)
+
+		got := []string{}
+		for _, d := range raw.(Docs) {
+			got = append(got, fmt.Sprintf("%s:%d:%s", filepath.Base(d.Fn), d.Row, d.Name))
+		}
+		assert.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestImplementsNotInterfaceMethod(t *testing.T) {
+	m := &mImplements{
+		Fn:     fixtureFile("ex/a/a.go"),
+		Env:    fixtureEnv(),
+		Offset: fixtureOffset(t, "ex/a/a.go", "func |New"),
+	}
+	_, err := m.Call()
+	assert.Equal(t, "New is not an interface method", err)
+}
+
+func TestIdxDirDeclaresInterfaces(t *testing.T) {
+	d := &idxDir{Files: map[string]*idxFile{
+		"a.go":      {Decls: []idxDecl{{Name: "T", Kind: "type", Sig: "struct"}}},
+		"a_test.go": {Decls: []idxDecl{{Name: "I", Kind: "type", Sig: "interface"}}},
+	}}
+	assert.Equal(t, false, d.declaresInterfaces(), "test file")
+
+	d.Files["b.go"] = &idxFile{Decls: []idxDecl{{Name: "I", Kind: "type", Sig: "interface"}}}
+	assert.Equal(t, true, d.declaresInterfaces(), "b.go")
+}
+
+func TestIdxDirDeclaresMethods(t *testing.T) {
+	d := &idxDir{Files: map[string]*idxFile{
+		"a.go": {Decls: []idxDecl{
+			{Name: "Read", Kind: "func", Recv: "*T"},
+			{Name: "Close", Kind: "func", Recv: "T"},
+			{Name: "Write", Kind: "func", Recv: "U"},
+			{Name: "Flush", Kind: "func"},
+		}},
+		"a_test.go": {Decls: []idxDecl{
+			{Name: "Seek", Kind: "func", Recv: "T"},
+		}},
+	}}
+
+	assert.Equal(t, true, d.declaresMethods([]string{"Read", "Close"}), "T")
+	assert.Equal(t, false, d.declaresMethods([]string{"Read", "Write"}), "T and U")
+	assert.Equal(t, false, d.declaresMethods([]string{"Flush"}), "func")
+	assert.Equal(t, false, d.declaresMethods([]string{"Seek"}), "test file")
+}
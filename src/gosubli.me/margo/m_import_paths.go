@@ -98,10 +98,10 @@ func importPaths(environ map[string]string, installSuffix string) ([]string, err
 	}
 	for root, _ := range paths {
 		root = filepath.Join(root, "pkg", osArchSfx)
-		walkF := func(p string, info os.FileInfo, err error) error {
-			if err == nil && !info.IsDir() {
-				p, e := filepath.Rel(root, p)
-				if e == nil && sfx(p, ".a") {
+		pkgIdx.walk(environ, root, true, nil, func(dir string, d *idxDir) {
+			for _, nm := range d.Archives {
+				p, e := filepath.Rel(root, filepath.Join(dir, nm))
+				if e == nil {
 					p := p[:len(p)-2]
 					if !pfx(p, ".") && !pfx(p, "_") && !sfx(p, "_test") {
 						p = path.Clean(filepath.ToSlash(p))
@@ -112,9 +112,7 @@ func importPaths(environ map[string]string, installSuffix string) ([]string, err
 					}
 				}
 			}
-			return nil
-		}
-		filepath.Walk(root, walkF)
+		})
 	}
 	return imports, nil
 }
@@ -26,6 +26,13 @@ type mImports struct {
 	TabIndent bool
 	Env       map[string]string
 	Autoinst  bool
+	// Auto adds the imports needed to resolve the package selectors in the file and removes unused ones,
+	// in addition to the imports in Toggle
+	Auto bool
+	// Edits returns the changes as a list of edits against Src instead of the partial source
+	Edits bool
+
+	impPolicy
 }
 
 func (m *mImports) Call() (interface{}, string) {
@@ -55,8 +62,15 @@ func (m *mImports) Call() (interface{}, string) {
 			}
 		}
 
-		af = imp(fset, af, m.Toggle)
-		src, err = printSrc(fset, af, m.TabIndent, m.TabWidth)
+		toggle := m.Toggle
+		if m.Auto {
+			toggle = append(autoImports(m.Fn, m.Src, m.Env), toggle...)
+		}
+
+		af = imp(fset, af, toggle)
+		if src, err = printSrc(fset, af, m.TabIndent, m.TabWidth); err == nil {
+			src, err = m.impPolicy.apply(src, m.TabIndent, m.TabWidth)
+		}
 	}
 
 	if m.Autoinst {
@@ -67,9 +81,20 @@ func (m *mImports) Call() (interface{}, string) {
 	}
 
 	res := M{
-		"src":     src,
 		"lineRef": lineRef,
 	}
+	if err == nil && m.Edits {
+		var orig string
+		if orig, err = readSrc(m.Fn, m.Src); err == nil {
+			l := splitLines(orig)
+			if lineRef < len(l) {
+				l = l[:lineRef]
+			}
+			res["edits"] = diffEdits(strings.Join(l, ""), src)
+		}
+	} else {
+		res["src"] = src
+	}
 	return res, errStr(err)
 }
 
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"errors"
 	"os/exec"
 	"sync"
 )
 
 var (
-	cmdWatchlist = map[string]*exec.Cmd{}
+	cmdWatchlist = map[string]*cmdWatch{}
 	cmdWatchLck  = sync.Mutex{}
+
+	errCmdKilled = errors.New("killed")
 )
 
+// cmdWatch is a command, or a chain of commands run one after the other, that can be killed by its id
+type cmdWatch struct {
+	// c is the command that's running, or was run last
+	c      *exec.Cmd
+	killed bool
+}
+
 type mKill struct {
 	Cid string
 }
@@ -22,18 +32,70 @@ func (m *mKill) Call() (res interface{}, err string) {
 }
 
 func watchCmd(id string, c *exec.Cmd) bool {
-	if id == "" {
+	w := watchChain(id)
+	if w == nil {
 		return false
 	}
 
+	cmdWatchLck.Lock()
+	defer cmdWatchLck.Unlock()
+	w.c = c
+	return true
+}
+
+// watchChain registers id for a chain of commands that are started with start, until unwatchCmd(id) is called.
+// it returns nil if id is already registered, in which case the chain can't be killed
+func watchChain(id string) *cmdWatch {
+	if id == "" {
+		return nil
+	}
+
 	cmdWatchLck.Lock()
 	defer cmdWatchLck.Unlock()
 
 	if _, ok := cmdWatchlist[id]; ok {
+		return nil
+	}
+	w := &cmdWatch{}
+	cmdWatchlist[id] = w
+	return w
+}
+
+// start starts c as the current command of the chain, it returns errCmdKilled instead if the chain was killed.
+// w may be nil, in which case c is simply started
+func (w *cmdWatch) start(c *exec.Cmd) error {
+	if w == nil {
+		return c.Start()
+	}
+
+	cmdWatchLck.Lock()
+	if w.killed {
+		cmdWatchLck.Unlock()
+		return errCmdKilled
+	}
+	w.c = c
+	cmdWatchLck.Unlock()
+
+	err := c.Start()
+
+	// the kill may have arrived before the process existed
+	cmdWatchLck.Lock()
+	defer cmdWatchLck.Unlock()
+	if err == nil && w.killed {
+		c.Process.Kill()
+	}
+	return err
+}
+
+// isKilled returns true if the chain was killed via killCmd
+func (w *cmdWatch) isKilled() bool {
+	if w == nil {
 		return false
 	}
-	cmdWatchlist[id] = c
-	return true
+
+	cmdWatchLck.Lock()
+	defer cmdWatchLck.Unlock()
+	return w.killed
 }
 
 func unwatchCmd(id string) bool {
@@ -59,10 +121,14 @@ func killCmd(id string) bool {
 	cmdWatchLck.Lock()
 	defer cmdWatchLck.Unlock()
 
-	if c, ok := cmdWatchlist[id]; ok {
+	if w, ok := cmdWatchlist[id]; ok {
 		// the primary use-case for these functions are remote requests to cancel the proces
 		// so we won't remove it from the map
-		c.Process.Kill()
+		// the process might not have been started yet, the owner checks killed before starting the next one
+		if w.c != nil && w.c.Process != nil {
+			w.c.Process.Kill()
+		}
+		w.killed = true
 		// neither wait nor release are called because the cmd owner should be waiting on it
 		return true
 	}
@@ -73,9 +139,11 @@ func init() {
 	byeDefer(func() {
 		cmdWatchLck.Lock()
 		defer cmdWatchLck.Unlock()
-		for _, c := range cmdWatchlist {
-			c.Process.Kill()
-			c.Process.Release()
+		for _, w := range cmdWatchlist {
+			if w.c != nil && w.c.Process != nil {
+				w.c.Process.Kill()
+				w.c.Process.Release()
+			}
 		}
 	})
 
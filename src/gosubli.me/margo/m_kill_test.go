@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKillChain(t *testing.T) {
+	id := "test.kill.chain"
+	chain := watchChain(id)
+	defer unwatchCmd(id)
+	assert.Equal(t, true, chain != nil, "watchChain")
+	assert.Equal(t, true, watchChain(id) == nil, "watchChain twice")
+
+	c := exec.Command("true")
+	assert.Equal(t, nil, chain.start(c), "start")
+	c.Wait()
+
+	// between two steps there's no process to kill, the next step must not start
+	assert.Equal(t, true, killCmd(id), "killCmd")
+	assert.Equal(t, true, chain.isKilled(), "isKilled")
+	c = exec.Command("true")
+	assert.Equal(t, errCmdKilled, chain.start(c), "start after kill")
+	assert.Equal(t, true, c.Process == nil, "process started after kill")
+}
+
+func TestKillShChain(t *testing.T) {
+	m := &mSh{
+		Cid: "test.kill.sh",
+		Cmd: mShCmd{
+			Name: "true",
+			And:  &mShCmd{Name: "sleep", Args: []string{"10"}},
+		},
+	}
+	// kill the chain as soon as it's registered, whichever step it's at
+	go func() {
+		for !killCmd(m.Cid) {
+		}
+	}()
+	res, err := m.Call()
+	assert.Equal(t, true, err != "", "error")
+	assert.Equal(t, true, len(res.(M)["steps"].([]M)) <= 2, "steps")
+	cmdWatchLck.Lock()
+	_, ok := cmdWatchlist[m.Cid]
+	cmdWatchLck.Unlock()
+	assert.Equal(t, false, ok, "unwatched")
+}
+
+func TestKillShChainSteps(t *testing.T) {
+	// the kill usually lands between two steps: the step that wasn't started must not be recorded
+	for i := 0; i < 20; i++ {
+		m := &mSh{
+			Cid: "test.kill.sh.steps",
+			Cmd: mShCmd{Name: "true"},
+		}
+		for sc, j := &m.Cmd, 0; j < 10; j++ {
+			sc.And = &mShCmd{Name: "true"}
+			sc = sc.And
+		}
+		go func() {
+			for !killCmd(m.Cid) {
+			}
+		}()
+		res, err := m.Call()
+		assert.Equal(t, true, err != "", "error")
+		for _, step := range res.(M)["steps"].([]M) {
+			assert.Equal(t, true, step["error"] != errCmdKilled.Error(), "step that wasn't started")
+		}
+	}
+}
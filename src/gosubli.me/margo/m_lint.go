@@ -8,15 +8,34 @@ import (
 	"regexp"
 	"strconv"
 
+	"gosubli.me/something-borrowed/gcimporter"
 	"gosubli.me/something-borrowed/types"
 )
 
 type mLintReport struct {
-	Fn      string
-	Row     int
-	Col     int
-	Message string
-	Kind    string
+	Fn       string
+	Row      int
+	Col      int
+	Message  string
+	Kind     string
+	Severity string
+	Fix      string
+	Fixes    []quickFix `json:",omitempty"`
+}
+
+// mLinter describes a single check. Kind is the name reported to, and filtered by, the client.
+// Fix is an optional suggestion shown along with the message
+type mLinter struct {
+	Kind     string
+	Severity string
+	Fix      string
+	Check    func(kind string, m *mLint)
+}
+
+type mLintTypes struct {
+	pkg  *types.Package
+	info *types.Info
+	errs []error
 }
 
 type mLint struct {
@@ -33,16 +52,44 @@ type mLint struct {
 	fset    *token.FileSet
 	af      *ast.File
 	reports []mLintReport
+	types   *mLintTypes
+
+	cancelable
 }
 
+const (
+	mLintError   = "error"
+	mLintWarning = "warning"
+)
+
 var (
 	mLintErrPat = regexp.MustCompile(`(.+?):(\d+):(\d+): (.+)`)
-	mLinters    = map[string]func(kind string, m *mLint){
-		"gs.flag.parse": mLintCheckFlagParse,
-		"gs.types":      mLintCheckTypes,
+	mLinters    = map[string]mLinter{}
+
+	// mLintFlagDefs are the functions of package flag that define a flag
+	mLintFlagDefs = map[string]bool{
+		"Var": true, "Bool": true, "BoolVar": true, "String": true, "StringVar": true,
+		"Int": true, "IntVar": true, "Uint": true, "UintVar": true, "Int64": true, "Int64Var": true,
+		"Uint64": true, "Uint64Var": true, "Duration": true, "DurationVar": true, "Float64": true, "Float64Var": true,
 	}
 )
 
+func registerLinter(l mLinter) {
+	if l.Kind == "" {
+		panic("Cannot register linter without a kind")
+	}
+	if l.Check == nil {
+		panic("Linter " + l.Kind + " is nil")
+	}
+	if _, ok := mLinters[l.Kind]; ok {
+		panic("Linter " + l.Kind + " is already registered")
+	}
+	if l.Severity == "" {
+		l.Severity = mLintWarning
+	}
+	mLinters[l.Kind] = l
+}
+
 func (m *mLint) Call() (interface{}, string) {
 	m.v.fn = m.Fn.String()
 	m.v.dir = m.Dir.String()
@@ -57,19 +104,23 @@ func (m *mLint) Call() (interface{}, string) {
 	m.reports = []mLintReport{}
 	m.fset, m.af, err = parseAstFile(m.v.fn, m.v.src, parser.DeclarationErrors)
 	if err == nil {
-		for kind, f := range mLinters {
+		for kind, l := range mLinters {
+			if m.sig.cancelled() {
+				break
+			}
 			if !filterKind[kind] {
-				f(kind, m)
+				l.Check(kind, m)
 			}
 		}
 	} else if el, ok := err.(scanner.ErrorList); ok && !filterKind["gs.syntax"] {
 		for _, e := range el {
 			m.report(mLintReport{
-				Fn:      m.v.fn,
-				Row:     e.Pos.Line - 1,
-				Col:     e.Pos.Column - 1,
-				Message: e.Msg,
-				Kind:    "gs.syntax",
+				Fn:       m.v.fn,
+				Row:      e.Pos.Line - 1,
+				Col:      e.Pos.Column - 1,
+				Message:  e.Msg,
+				Kind:     "gs.syntax",
+				Severity: mLintError,
 			})
 		}
 	}
@@ -81,13 +132,48 @@ func (m *mLint) Call() (interface{}, string) {
 }
 
 func (m *mLint) report(reps ...mLintReport) {
-	m.reports = append(m.reports, reps...)
+	for _, r := range reps {
+		if l, ok := mLinters[r.Kind]; ok {
+			if r.Severity == "" {
+				r.Severity = l.Severity
+			}
+			if r.Fix == "" {
+				r.Fix = l.Fix
+			}
+		}
+		m.reports = append(m.reports, r)
+	}
+}
+
+// reportNode reports msg at the position of node
+func (m *mLint) reportNode(kind string, node ast.Node, msg string) {
+	if tp := m.fset.Position(node.Pos()); tp.IsValid() {
+		m.report(mLintReport{
+			Fn:      tp.Filename,
+			Row:     tp.Line - 1,
+			Col:     tp.Column - 1,
+			Message: msg,
+			Kind:    kind,
+		})
+	}
 }
 
 func init() {
 	registry.Register("lint", func(_ *Broker) Caller {
 		return &mLint{}
 	})
+
+	registerLinter(mLinter{
+		Kind:  "gs.flag.parse",
+		Fix:   "call flag.Parse() before the flag values are used",
+		Check: mLintCheckFlagParse,
+	})
+
+	registerLinter(mLinter{
+		Kind:     "gs.types",
+		Severity: mLintError,
+		Check:    mLintCheckTypes,
+	})
 }
 
 func mLintCheckFlagParse(kind string, m *mLint) {
@@ -101,8 +187,10 @@ func mLintCheckFlagParse(kind string, m *mLint) {
 					switch sel.Sel.String() {
 					case "Parse":
 						foundParse = true
-					case "Var", "Bool", "BoolVar", "String", "StringVar", "Int", "IntVar", "Uint", "UintVar", "Int64", "Int64Var", "Uint64", "Uint64Var", "Duration", "DurationVar", "Float64", "Float64Var":
-
+					default:
+						if !mLintFlagDefs[sel.Sel.String()] {
+							break
+						}
 						if !foundParse && c != nil {
 							tp := m.fset.Position(c.Pos())
 							if tp.IsValid() {
@@ -122,12 +210,31 @@ func mLintCheckFlagParse(kind string, m *mLint) {
 		return !foundParse
 	})
 
-	if !foundParse {
+	if !foundParse && len(reps) > 0 {
+		fixes := mLintFlagParseFix(m)
+		for i := range reps {
+			reps[i].Fixes = fixes
+		}
 		m.report(reps...)
 	}
 }
 
-func mLintCheckTypes(kind string, m *mLint) {
+// typeCheck type-checks the package of the current file.
+// the result is computed once and shared by all the linters that need type information
+func (m *mLint) typeCheck() *mLintTypes {
+	if m.types != nil {
+		return m.types
+	}
+
+	m.types = &mLintTypes{
+		info: &types.Info{
+			Types:  map[ast.Expr]types.TypeAndValue{},
+			Defs:   map[*ast.Ident]types.Object{},
+			Uses:   map[*ast.Ident]types.Object{},
+			Scopes: map[ast.Node]*types.Scope{},
+		},
+	}
+
 	files := []*ast.File{m.af}
 	if m.v.dir != "" {
 		pkg, pkgs, _ := parsePkg(m.fset, m.v.dir, parser.ParseComments)
@@ -140,7 +247,7 @@ func mLintCheckTypes(kind string, m *mLint) {
 			}
 
 			if pkg == nil {
-				return
+				return m.types
 			}
 		}
 
@@ -152,22 +259,39 @@ func mLintCheckTypes(kind string, m *mLint) {
 	}
 
 	ctx := types.Config{
-		Error: func(err error) {
-			s := mLintErrPat.FindStringSubmatch(err.Error())
-			if len(s) == 5 {
-				line, _ := strconv.Atoi(s[2])
-				column, _ := strconv.Atoi(s[3])
-
-				m.report(mLintReport{
-					Fn:      s[1],
-					Row:     line - 1,
-					Col:     column - 1,
-					Message: s[4],
-					Kind:    kind,
-				})
+		Import: func(imports map[string]*types.Package, path string) (*types.Package, error) {
+			if m.sig.cancelled() {
+				return nil, cancelledErr
 			}
+			return gcimporter.Import(imports, path)
+		},
+		Error: func(err error) {
+			m.types.errs = append(m.types.errs, err)
 		},
 	}
 
-	ctx.Check(m.v.dir, m.fset, files, nil)
+	m.types.pkg, _ = ctx.Check(m.v.dir, m.fset, files, m.types.info)
+	return m.types
+}
+
+func mLintCheckTypes(kind string, m *mLint) {
+	for _, err := range m.typeCheck().errs {
+		s := mLintErrPat.FindStringSubmatch(err.Error())
+		if len(s) == 5 {
+			line, _ := strconv.Atoi(s[2])
+			column, _ := strconv.Atoi(s[3])
+
+			rep := mLintReport{
+				Fn:      s[1],
+				Row:     line - 1,
+				Col:     column - 1,
+				Message: s[4],
+				Kind:    kind,
+			}
+			if e, ok := err.(types.Error); ok {
+				rep.Fixes = mLintTypesFixes(m, e)
+			}
+			m.report(rep)
+		}
+	}
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"gosubli.me/something-borrowed/types"
+)
+
+// quickFix is a set of edits that the client can apply to fix the problem described by a lint report
+type quickFix struct {
+	Title string     `json:"title"`
+	Edits []textEdit `json:"edits"`
+}
+
+type mLintFix struct {
+	Fn  jString
+	Src jString
+	Fix quickFix
+}
+
+func (m *mLintFix) Call() (interface{}, string) {
+	res := M{}
+	src := m.Src.String()
+	if src == "" {
+		s, err := ioutil.ReadFile(m.Fn.String())
+		if err != nil {
+			return res, err.Error()
+		}
+		src = string(s)
+	}
+
+	src, err := applyEdits(src, m.Fix.Edits)
+	if err == nil {
+		res["src"] = src
+	}
+	return res, errStr(err)
+}
+
+func init() {
+	registry.Register("lint_fix", func(_ *Broker) Caller {
+		return &mLintFix{}
+	})
+}
+
+// mLintFlagParseFix returns a fix that inserts a call to flag.Parse() into main
+// after the last statement that defines a flag, or at the start of it
+func mLintFlagParseFix(m *mLint) []quickFix {
+	for _, decl := range m.af.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil || fd.Name.Name != "main" || fd.Body == nil {
+			continue
+		}
+
+		pos := fd.Body.Lbrace + 1
+		indent := "\t"
+		if len(fd.Body.List) != 0 {
+			indent = mLintIndent(m, fd.Body.List[0])
+		}
+		for _, stmt := range fd.Body.List {
+			definesFlag := false
+			ast.Inspect(stmt, func(node ast.Node) bool {
+				if c, ok := node.(*ast.CallExpr); ok {
+					if sel, ok := c.Fun.(*ast.SelectorExpr); ok {
+						if id, ok := sel.X.(*ast.Ident); ok && id.Name == "flag" && mLintFlagDefs[sel.Sel.Name] {
+							definesFlag = true
+						}
+					}
+				}
+				return !definesFlag
+			})
+			if definesFlag {
+				pos = stmt.End()
+				indent = mLintIndent(m, stmt)
+			}
+		}
+
+		return []quickFix{{
+			Title: "Insert flag.Parse()",
+			Edits: []textEdit{posEdit(m.fset, pos, pos, "\n"+indent+"flag.Parse()")},
+		}}
+	}
+	return nil
+}
+
+// mLintIndent returns the whitespace that precedes node on its line
+func mLintIndent(m *mLint, node ast.Node) string {
+	p := m.fset.Position(node.Pos())
+	if p.Offset > len(m.v.src) || p.Column < 1 {
+		return "\t"
+	}
+	line := m.v.src[p.Offset-(p.Column-1) : p.Offset]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// mLintTypesFixes returns fixes for the type-checker error err
+func mLintTypesFixes(m *mLint, err types.Error) []quickFix {
+	if m.fset.Position(err.Pos).Filename != m.fset.Position(m.af.Pos()).Filename {
+		return nil
+	}
+
+	switch {
+	case strings.HasSuffix(err.Msg, "imported but not used") || strings.Contains(err.Msg, "imported but not used as "):
+		if fix, ok := mLintRemoveImportFix(m, err.Pos); ok {
+			return []quickFix{fix}
+		}
+	case strings.HasPrefix(err.Msg, "undeclared name: "):
+		return mLintAddImportFixes(m, strings.TrimPrefix(err.Msg, "undeclared name: "))
+	}
+	return nil
+}
+
+func mLintRemoveImportFix(m *mLint, pos token.Pos) (quickFix, bool) {
+	for _, decl := range m.af.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+
+		for _, spec := range gd.Specs {
+			ispec, ok := spec.(*ast.ImportSpec)
+			if !ok || pos < ispec.Pos() || pos > ispec.End() {
+				continue
+			}
+
+			// remove the whole declaration if this is the only import in it
+			node := ast.Node(ispec)
+			if !gd.Lparen.IsValid() || len(gd.Specs) == 1 {
+				node = gd
+			}
+
+			fix := quickFix{
+				Title: "Remove import " + ispec.Path.Value,
+				Edits: []textEdit{lineEdit(
+					m.fset.Position(node.Pos()).Line-1,
+					m.fset.Position(node.End()).Line-1,
+					"",
+				)},
+			}
+			return fix, true
+		}
+	}
+	return quickFix{}, false
+}
+
+// mLintAddImportFixes returns a fix for each standard package named name
+func mLintAddImportFixes(m *mLint, name string) []quickFix {
+	imported := map[string]bool{}
+	for _, p := range fileImportPaths(m.af) {
+		imported[p] = true
+	}
+
+	fixes := []quickFix{}
+	for _, p := range stdPkg {
+		if path.Base(p) != name || strings.HasPrefix(p, "cmd/") || imported[p] {
+			continue
+		}
+
+		ipath := strconv.Quote(p)
+		edit := posEdit(m.fset, m.af.Name.End(), m.af.Name.End(), "\n\nimport "+ipath)
+		for _, decl := range m.af.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT && gd.Lparen.IsValid() {
+				edit = posEdit(m.fset, gd.Lparen+1, gd.Lparen+1, "\n\t"+ipath)
+				break
+			}
+		}
+
+		fixes = append(fixes, quickFix{
+			Title: "Add import " + ipath,
+			Edits: []textEdit{edit},
+		})
+	}
+	return fixes
+}
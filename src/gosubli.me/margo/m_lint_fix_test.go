@@ -0,0 +1,71 @@
+package main
+
+import (
+	"go/parser"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lintFixSrc(t *testing.T, src string, fix quickFix) string {
+	m := &mLintFix{
+		Src: jString(src),
+		Fix: fix,
+	}
+	res, err := m.Call()
+	assert.Equal(t, "", err, "error")
+	s, _ := res.(M)["src"].(string)
+	return s
+}
+
+func TestLintFixRemoveImport(t *testing.T) {
+	src := "package a\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nvar _ = os.Args\n"
+	m := &mLint{}
+	m.fset, m.af, _ = parseAstFile("a.go", src, parser.DeclarationErrors)
+
+	fix, ok := mLintRemoveImportFix(m, m.af.Imports[0].Pos())
+	assert.Equal(t, true, ok, "found")
+	assert.Equal(t, "package a\n\nimport (\n\t\"os\"\n)\n\nvar _ = os.Args\n", lintFixSrc(t, src, fix))
+}
+
+func TestLintFixAddImport(t *testing.T) {
+	src := "package a\n\nfunc f() []string {\n\treturn strings.Fields(\"\")\n}\n"
+	m := &mLint{}
+	m.fset, m.af, _ = parseAstFile("a.go", src, parser.DeclarationErrors)
+
+	fixes := mLintAddImportFixes(m, "strings")
+	if assert.Len(t, fixes, 1) {
+		want := "package a\n\nimport \"strings\"\n\nfunc f() []string {\n\treturn strings.Fields(\"\")\n}\n"
+		assert.Equal(t, want, lintFixSrc(t, src, fixes[0]))
+	}
+}
+
+func TestLintFixFlagParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			"package main\n\nimport \"flag\"\n\nfunc main() {\n\tv := flag.Bool(\"v\", false, \"\")\n\tprintln(*v)\n}\n",
+			"package main\n\nimport \"flag\"\n\nfunc main() {\n\tv := flag.Bool(\"v\", false, \"\")\n\tflag.Parse()\n\tprintln(*v)\n}\n",
+		},
+		{
+			// uses of the flags don't define any, and the indentation is that of the definition
+			"package main\n\nimport \"flag\"\n\nfunc main() {\n    v := flag.Bool(\"v\", false, \"\")\n    if flag.NArg() != 0 {\n        println(flag.Arg(0), *v)\n    }\n}\n",
+			"package main\n\nimport \"flag\"\n\nfunc main() {\n    v := flag.Bool(\"v\", false, \"\")\n    flag.Parse()\n    if flag.NArg() != 0 {\n        println(flag.Arg(0), *v)\n    }\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		m := &mLint{
+			Fn:     "a.go",
+			Src:    jString(tt.src),
+			Filter: []string{"gs.types"},
+		}
+		m.Call()
+
+		if assert.Len(t, m.reports, 1) && assert.Len(t, m.reports[0].Fixes, 1) {
+			assert.Equal(t, tt.want, lintFixSrc(t, tt.src, m.reports[0].Fixes[0]))
+		}
+	}
+}
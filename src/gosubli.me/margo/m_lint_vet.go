@@ -0,0 +1,520 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+
+	"gosubli.me/something-borrowed/types"
+)
+
+// the checks in this file are modelled after (a subset of) those performed by `go vet`
+
+var (
+	mLintPrintfFuncs = map[string]map[string]int{
+		// the value is the index of the format argument
+		"fmt": {
+			"Printf":  0,
+			"Sprintf": 0,
+			"Fprintf": 1,
+			"Errorf":  0,
+		},
+		"log": {
+			"Printf": 0,
+			"Fatalf": 0,
+			"Panicf": 0,
+		},
+	}
+
+	mLintLockTypes = map[string]bool{
+		"Mutex":     true,
+		"RWMutex":   true,
+		"WaitGroup": true,
+		"Cond":      true,
+		"Once":      true,
+	}
+)
+
+func init() {
+	registerLinter(mLinter{
+		Kind:  "gs.unreachable",
+		Fix:   "remove the unreachable code",
+		Check: mLintCheckUnreachable,
+	})
+
+	registerLinter(mLinter{
+		Kind:  "gs.printf",
+		Fix:   "make the format verbs agree with the arguments",
+		Check: mLintCheckPrintf,
+	})
+
+	registerLinter(mLinter{
+		Kind:  "gs.copylocks",
+		Fix:   "use a pointer instead of copying the value",
+		Check: mLintCheckCopyLocks,
+	})
+
+	registerLinter(mLinter{
+		Kind:  "gs.shadow",
+		Fix:   "rename the inner err or assign to the outer one with =",
+		Check: mLintCheckShadow,
+	})
+
+	registerLinter(mLinter{
+		Kind:     "gs.append",
+		Severity: mLintError,
+		Fix:      "assign the result e.g. s = append(s, v)",
+		Check:    mLintCheckAppend,
+	})
+
+	registerLinter(mLinter{
+		Kind:  "gs.selfassign",
+		Fix:   "remove the assignment",
+		Check: mLintCheckSelfAssign,
+	})
+
+	registerLinter(mLinter{
+		Kind:  "gs.loopclosure",
+		Fix:   "pass the variable to the func literal as an argument",
+		Check: mLintCheckLoopClosure,
+	})
+}
+
+func mLintCheckUnreachable(kind string, m *mLint) {
+	check := func(list []ast.Stmt) {
+		for i, stmt := range list {
+			if i+1 < len(list) && mLintTerminates(stmt) {
+				next := list[i+1]
+				// the next statement might be the target of a goto
+				if _, ok := next.(*ast.LabeledStmt); !ok {
+					m.reportNode(kind, next, "unreachable code")
+				}
+				return
+			}
+		}
+	}
+
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.BlockStmt:
+			check(n.List)
+		case *ast.CaseClause:
+			check(n.Body)
+		case *ast.CommClause:
+			check(n.Body)
+		}
+		return true
+	})
+}
+
+func mLintTerminates(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.GOTO || s.Tok == token.BREAK || s.Tok == token.CONTINUE
+	case *ast.ExprStmt:
+		if c, ok := s.X.(*ast.CallExpr); ok {
+			if id, ok := c.Fun.(*ast.Ident); ok && id.Name == "panic" && id.Obj == nil {
+				return true
+			}
+		}
+	case *ast.ForStmt:
+		// an infinite loop without a break
+		if s.Cond == nil {
+			brk := false
+			ast.Inspect(s.Body, func(node ast.Node) bool {
+				switch n := node.(type) {
+				case *ast.BranchStmt:
+					if n.Tok == token.BREAK {
+						brk = true
+					}
+				case *ast.FuncLit:
+					return false
+				}
+				return !brk
+			})
+			return !brk
+		}
+	}
+	return false
+}
+
+func mLintCheckPrintf(kind string, m *mLint) {
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		c, ok := node.(*ast.CallExpr)
+		if !ok || c.Ellipsis.IsValid() {
+			return true
+		}
+
+		sel, ok := c.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		id, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		ipath := mLintImportPath(m, id)
+		idx, ok := mLintPrintfFuncs[ipath][sel.Sel.Name]
+		if !ok || idx >= len(c.Args) {
+			return true
+		}
+
+		lit, ok := c.Args[idx].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+
+		format, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+
+		name := ipath + "." + sel.Sel.Name
+		verbs, msg := mLintPrintfVerbs(format)
+		switch {
+		case msg != "":
+			m.reportNode(kind, c, name+" "+msg)
+		case verbs >= 0 && verbs != len(c.Args)-idx-1:
+			m.reportNode(kind, c, fmt.Sprintf("%s call needs %d args but has %d args", name, verbs, len(c.Args)-idx-1))
+		}
+		return true
+	})
+}
+
+// mLintImportPath returns the import path of the package named by id or "" if id doesn't name a package.
+// if the checker couldn't resolve id e.g. because the import failed, it's looked up in the file's imports
+func mLintImportPath(m *mLint, id *ast.Ident) string {
+	if obj, ok := m.typeCheck().info.Uses[id]; ok {
+		if pn, ok := obj.(*types.PkgName); ok {
+			return pn.Imported().Path()
+		}
+		return ""
+	}
+
+	if id.Obj != nil {
+		return ""
+	}
+	for _, spec := range m.af.Imports {
+		ipath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(ipath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == id.Name {
+			return ipath
+		}
+	}
+	return ""
+}
+
+// mLintPrintfVerbs returns the number of arguments consumed by format.
+// if the count cannot be determined (explicit argument indexes are used) it returns -1
+func mLintPrintfVerbs(format string) (n int, msg string) {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		for i += 1; i < len(format); i++ {
+			c := format[i]
+			switch {
+			case c == '[':
+				return -1, ""
+			case c == '*':
+				n += 1
+			case strings.IndexByte("+-# 0.", c) >= 0 || (c >= '0' && c <= '9'):
+			default:
+				goto verb
+			}
+		}
+		return n, "format ends in the middle of a verb"
+
+	verb:
+		switch c := format[i]; {
+		case c == '%':
+		case strings.IndexByte("vTtbcdoqxXUeEfFgGspw", c) >= 0:
+			n += 1
+		default:
+			return n, fmt.Sprintf("has unknown verb %%%c", c)
+		}
+	}
+	return n, ""
+}
+
+func mLintCheckCopyLocks(kind string, m *mLint) {
+	info := m.typeCheck().info
+
+	check := func(x ast.Expr, what string) {
+		switch x.(type) {
+		case *ast.CompositeLit, *ast.CallExpr, *ast.FuncLit:
+			// new values, nothing is copied
+			return
+		}
+
+		if tv, ok := info.Types[x]; ok && tv.IsType() {
+			return
+		}
+
+		if path := mLintLockPath(info.TypeOf(x)); path != "" {
+			m.reportNode(kind, x, fmt.Sprintf("%s copies lock value: %s", what, path))
+		}
+	}
+
+	checkFields := func(fl *ast.FieldList, what string) {
+		if fl == nil {
+			return
+		}
+		for _, f := range fl.List {
+			if path := mLintLockPath(info.TypeOf(f.Type)); path != "" {
+				m.reportNode(kind, f.Type, fmt.Sprintf("%s passes lock by value: %s", what, path))
+			}
+		}
+	}
+
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			checkFields(n.Recv, n.Name.Name)
+			checkFields(n.Type.Params, n.Name.Name)
+		case *ast.FuncLit:
+			checkFields(n.Type.Params, "func")
+		case *ast.AssignStmt:
+			for _, x := range n.Rhs {
+				check(x, "assignment")
+			}
+		case *ast.ValueSpec:
+			for _, x := range n.Values {
+				check(x, "variable declaration")
+			}
+		case *ast.ReturnStmt:
+			for _, x := range n.Results {
+				check(x, "return")
+			}
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; ok && tv.IsType() {
+				// conversions
+				return true
+			}
+			for _, x := range n.Args {
+				check(x, "call")
+			}
+		case *ast.RangeStmt:
+			if n.Value != nil {
+				if path := mLintLockPath(info.TypeOf(n.Value)); path != "" {
+					m.reportNode(kind, n.Value, "range var "+types.ExprString(n.Value)+" copies lock: "+path)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// mLintLockPath returns a description of the path to the lock contained in a value of type typ or "" if there is none
+func mLintLockPath(typ types.Type) string {
+	if typ == nil {
+		return ""
+	}
+
+	if named, ok := typ.(*types.Named); ok {
+		obj := named.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "sync" && mLintLockTypes[obj.Name()] {
+			return "sync." + obj.Name()
+		}
+	}
+
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if path := mLintLockPath(f.Type()); path != "" {
+				return typ.String() + " contains " + path
+			}
+		}
+	case *types.Array:
+		return mLintLockPath(t.Elem())
+	}
+
+	return ""
+}
+
+func mLintCheckShadow(kind string, m *mLint) {
+	tc := m.typeCheck()
+	if tc.pkg == nil {
+		return
+	}
+
+	// map the scopes back to their nodes so we know where they end
+	scopeNodes := map[*types.Scope]ast.Node{}
+	for node, scope := range tc.info.Scopes {
+		scopeNodes[scope] = node
+	}
+
+	check := func(id *ast.Ident) {
+		if id.Name != "err" {
+			return
+		}
+
+		inner, ok := tc.info.Defs[id].(*types.Var)
+		if !ok || inner.Parent() == nil || inner.Parent().Parent() == nil {
+			return
+		}
+
+		_, obj := inner.Parent().Parent().LookupParent(id.Name)
+		outer, ok := obj.(*types.Var)
+		if !ok || outer.Pos() >= inner.Pos() || !types.Identical(outer.Type(), inner.Type()) {
+			return
+		}
+
+		// package-level variables are not interesting
+		if p := outer.Parent(); p == nil || p == tc.pkg.Scope() || p == types.Universe {
+			return
+		}
+
+		// only report the shadowing if it matters i.e. the outer variable is used after the inner one goes out of scope
+		span := scopeNodes[inner.Parent()]
+		if span == nil {
+			return
+		}
+		for use, o := range tc.info.Uses {
+			if o == outer && use.Pos() > span.End() {
+				pos := m.fset.Position(outer.Pos())
+				m.reportNode(kind, id, fmt.Sprintf("declaration of err shadows declaration at line %d", pos.Line))
+				return
+			}
+		}
+	}
+
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, x := range n.Lhs {
+					if id, ok := x.(*ast.Ident); ok {
+						check(id)
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				check(id)
+			}
+		}
+		return true
+	})
+}
+
+func mLintCheckAppend(kind string, m *mLint) {
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		if s, ok := node.(*ast.ExprStmt); ok {
+			if c, ok := s.X.(*ast.CallExpr); ok {
+				if id, ok := c.Fun.(*ast.Ident); ok && id.Name == "append" && id.Obj == nil {
+					m.reportNode(kind, c, "result of append is not used")
+				}
+			}
+		}
+		return true
+	})
+}
+
+func mLintCheckSelfAssign(kind string, m *mLint) {
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		a, ok := node.(*ast.AssignStmt)
+		if !ok || a.Tok != token.ASSIGN || len(a.Lhs) != len(a.Rhs) {
+			return true
+		}
+
+		for i, lhs := range a.Lhs {
+			if !mLintPureExpr(lhs) {
+				continue
+			}
+			l := types.ExprString(lhs)
+			if l == types.ExprString(a.Rhs[i]) {
+				m.reportNode(kind, lhs, "self-assignment of "+l+" to "+l)
+			}
+		}
+		return true
+	})
+}
+
+// mLintPureExpr returns true if evaluating x has no side-effects
+func mLintPureExpr(x ast.Expr) bool {
+	switch v := x.(type) {
+	case *ast.Ident:
+		return v.Name != "_"
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return mLintPureExpr(v.X)
+	case *ast.SelectorExpr:
+		return mLintPureExpr(v.X)
+	case *ast.StarExpr:
+		return mLintPureExpr(v.X)
+	case *ast.IndexExpr:
+		return mLintPureExpr(v.X) && mLintPureExpr(v.Index)
+	}
+	return false
+}
+
+func mLintCheckLoopClosure(kind string, m *mLint) {
+	check := func(body *ast.BlockStmt, vars []*ast.Ident) {
+		if body == nil || len(body.List) == 0 || len(vars) == 0 {
+			return
+		}
+
+		// like vet, only the last statement is checked since that's where the goroutine
+		// is most likely to outlive the current iteration
+		g, ok := body.List[len(body.List)-1].(*ast.GoStmt)
+		if !ok {
+			return
+		}
+
+		lit, ok := g.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			return
+		}
+
+		ast.Inspect(lit.Body, func(node ast.Node) bool {
+			id, ok := node.(*ast.Ident)
+			if !ok || id.Obj == nil {
+				return true
+			}
+			for _, v := range vars {
+				if v.Obj == id.Obj {
+					m.reportNode(kind, id, "loop variable "+id.Name+" captured by func literal")
+				}
+			}
+			return true
+		})
+	}
+
+	ast.Inspect(m.af, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.RangeStmt:
+			vars := []*ast.Ident{}
+			for _, x := range []ast.Expr{n.Key, n.Value} {
+				if id, ok := x.(*ast.Ident); ok && n.Tok == token.DEFINE && id.Name != "_" {
+					vars = append(vars, id)
+				}
+			}
+			check(n.Body, vars)
+		case *ast.ForStmt:
+			vars := []*ast.Ident{}
+			if a, ok := n.Init.(*ast.AssignStmt); ok && a.Tok == token.DEFINE {
+				for _, x := range a.Lhs {
+					if id, ok := x.(*ast.Ident); ok && id.Name != "_" {
+						vars = append(vars, id)
+					}
+				}
+			}
+			check(n.Body, vars)
+		}
+		return true
+	})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gosubli.me/something-borrowed/types"
+)
+
+func lintKinds(src string) map[string][]int {
+	m := &mLint{
+		Fn:     "a.go",
+		Src:    jString(src),
+		Filter: []string{"gs.types"},
+	}
+	m.Call()
+
+	kinds := map[string][]int{}
+	for _, r := range m.reports {
+		kinds[r.Kind] = append(kinds[r.Kind], r.Row)
+	}
+	return kinds
+}
+
+func TestLintPrintfVerbs(t *testing.T) {
+	tests := []struct {
+		format string
+		n      int
+		msg    string
+	}{
+		{"", 0, ""},
+		{"100%%", 0, ""},
+		{"%d %s", 2, ""},
+		{"%*d", 2, ""},
+		{"%-8.3f|%+v", 2, ""},
+		{"%[1]d %[1]d", -1, ""},
+		{"%", 0, "format ends in the middle of a verb"},
+		{"%y", 0, "has unknown verb %y"},
+	}
+
+	for _, tt := range tests {
+		n, msg := mLintPrintfVerbs(tt.format)
+		assert.Equal(t, tt.n, n, tt.format)
+		assert.Equal(t, tt.msg, msg, tt.format)
+	}
+}
+
+func TestLintVetChecks(t *testing.T) {
+	kinds := lintKinds(`package a
+
+func f(l []int) int {
+	x := 1
+	x = x
+	append(l, x)
+	for i := range l {
+		go func() {
+			println(i)
+		}()
+	}
+	return x
+	println("dead")
+}
+`)
+
+	assert.Equal(t, []int{4}, kinds["gs.selfassign"], "selfassign")
+	assert.Equal(t, []int{5}, kinds["gs.append"], "append")
+	assert.Equal(t, []int{8}, kinds["gs.loopclosure"], "loopclosure")
+	assert.Equal(t, []int{12}, kinds["gs.unreachable"], "unreachable")
+}
+
+func TestLintFilter(t *testing.T) {
+	m := &mLint{
+		Fn:     "a.go",
+		Src:    "package a\n\nfunc f() {\n\treturn\n\tprintln()\n}\n",
+		Filter: []string{"gs.types", "gs.unreachable"},
+	}
+	m.Call()
+	assert.Equal(t, 0, len(m.reports), "reports")
+}
+
+// lintCheck runs the check on src, type-checked against the fake packages pkgs instead of the installed archives
+func lintCheck(src string, check func(string, *mLint), pkgs ...*types.Package) []int {
+	m := &mLint{}
+	m.reports = []mLintReport{}
+	m.fset, m.af, _ = parseAstFile("a.go", src, parser.DeclarationErrors)
+	m.types = &mLintTypes{
+		info: &types.Info{
+			Types:  map[ast.Expr]types.TypeAndValue{},
+			Defs:   map[*ast.Ident]types.Object{},
+			Uses:   map[*ast.Ident]types.Object{},
+			Scopes: map[ast.Node]*types.Scope{},
+		},
+	}
+	ctx := types.Config{
+		Import: func(_ map[string]*types.Package, path string) (*types.Package, error) {
+			for _, p := range pkgs {
+				if p.Path() == path {
+					return p, nil
+				}
+			}
+			return nil, fmt.Errorf("can't find import: %s", path)
+		},
+		Error: func(error) {},
+	}
+	m.types.pkg, _ = ctx.Check("a", m.fset, []*ast.File{m.af}, m.types.info)
+
+	check("test", m)
+	rows := []int{}
+	for _, r := range m.reports {
+		rows = append(rows, r.Row)
+	}
+	return rows
+}
+
+// lintFakePkg returns a complete package that declares the named struct types
+func lintFakePkg(path string, names ...string) *types.Package {
+	pkg := types.NewPackage(path, filepath.Base(path))
+	for _, name := range names {
+		obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+		field := types.NewField(token.NoPos, pkg, "state", types.Typ[types.Int32], false)
+		types.NewNamed(obj, types.NewStruct([]*types.Var{field}, nil), nil)
+		pkg.Scope().Insert(obj)
+	}
+	pkg.MarkComplete()
+	return pkg
+}
+
+func TestLintPrintf(t *testing.T) {
+	src := `package a
+
+import (
+	"fmt"
+	l "log"
+)
+
+type logger struct{}
+
+func (logger) Printf(string, ...interface{}) {}
+
+func f() {
+	fmt.Printf("%d %d", 1)
+	l.Fatalf("%s", 1, 2)
+	fmt.Sprintf("%d", 1)
+	log := logger{}
+	log.Printf("%d")
+}
+`
+	fmtPkg := lintFakePkg("fmt")
+	logPkg := lintFakePkg("log")
+	assert.Equal(t, []int{12, 13}, lintCheck(src, mLintCheckPrintf, fmtPkg, logPkg), "resolved")
+	// the imports can't be resolved, so they're looked up in the file
+	assert.Equal(t, []int{12, 13}, lintCheck(src, mLintCheckPrintf), "unresolved")
+}
+
+func TestLintCopyLocks(t *testing.T) {
+	src := `package a
+
+import "sync"
+
+type T struct {
+	mu sync.Mutex
+}
+
+func byValue(t T) {}
+
+func f(t *T, l []T) T {
+	u := *t
+	v := &T{}
+	byValue(*v)
+	for _, x := range l {
+		_ = x
+	}
+	w := new(sync.Mutex)
+	_ = w
+	return u
+}
+`
+	syncPkg := lintFakePkg("sync", "Mutex")
+	assert.Equal(t, []int{8, 11, 13, 14, 15, 19}, lintCheck(src, mLintCheckCopyLocks, syncPkg), "copylocks")
+
+	// types that only look like locks aren't reported
+	notSync := lintFakePkg("example.com/sync", "Mutex")
+	assert.Equal(t, []int{}, lintCheck(strings.Replace(src, `"sync"`, `sync "example.com/sync"`, 1), mLintCheckCopyLocks, notSync), "not sync")
+}
+
+func TestLintShadow(t *testing.T) {
+	src := `package a
+
+func g() error { return nil }
+
+func f() error {
+	err := g()
+	if true {
+		err := g()
+		_ = err
+	}
+	if err := g(); err != nil {
+		return err
+	}
+	return err
+}
+
+func h() {
+	err := g()
+	_ = err
+	if true {
+		err := g()
+		_ = err
+	}
+	var n int
+	if true {
+		n := 1
+		_ = n
+	}
+	_ = n
+}
+`
+	// in h, the outer err isn't used after the inner one goes out of scope and n isn't an error
+	assert.Equal(t, []int{7, 10}, lintCheck(src, mLintCheckShadow), "shadow")
+}
@@ -12,6 +12,17 @@ import (
 type mPkgdoc struct {
 	Q    jString
 	Path jString
+	// Local builds the documentation from the package source, and searches the local package index, instead of querying godoc.org.
+	// the documentation is returned as text in `doc` and, as structured data, in `package`
+	Local bool
+	Env   map[string]string
+	// Limit is the maximum number of results of local searches
+	Limit int
+	// Width is the width the doc comments of local documentation are wrapped at
+	Width int
+
+	remoteConfig
+	cancelable
 }
 
 type mPkgdocDoc struct {
@@ -31,13 +42,13 @@ func mPkgdocFetchDoc(m *mPkgdoc) (interface{}, string) {
 		return res, "invalid query"
 	}
 
-	req, err := http.NewRequest("GET", "http://godoc.org/"+path, nil)
+	req, err := http.NewRequest("GET", m.baseURL()+"/"+path, nil)
 	if err != nil {
 		return res, errStr(err)
 	}
 
 	setupReq(req)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.do(m.Env, m.sig, req)
 	if err != nil {
 		return res, errStr(err)
 	}
@@ -62,13 +73,13 @@ func mPkgdocSearch(m *mPkgdoc) (interface{}, string) {
 		return res, "invalid query"
 	}
 
-	req, err := http.NewRequest("GET", "http://godoc.org/?q="+url.QueryEscape(s), nil)
+	req, err := http.NewRequest("GET", m.baseURL()+"/?q="+url.QueryEscape(s), nil)
 	if err != nil {
 		return res, errStr(err)
 	}
 
 	setupReq(req)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.do(m.Env, m.sig, req)
 	if err != nil {
 		return res, errStr(err)
 	}
@@ -100,8 +111,49 @@ func mPkgdocSearch(m *mPkgdoc) (interface{}, string) {
 	return res, ""
 }
 
+func mPkgdocLocalDoc(m *mPkgdoc) (interface{}, string) {
+	res := M{}
+	path := strings.TrimSpace(m.Path.String())
+	if path == "" {
+		return res, "invalid query"
+	}
+
+	p, err := pkgdocBuild(buildContext(m.Env), path)
+	if err != nil {
+		return res, errStr(err)
+	}
+
+	res["doc"] = mPkgdocDoc{
+		Path: p.ImportPath,
+		Doc:  pkgdocText(p, m.Width),
+	}
+	res["package"] = p
+	return res, ""
+}
+
+func mPkgdocLocalSearch(m *mPkgdoc) (interface{}, string) {
+	res := M{}
+	s := strings.TrimSpace(m.Q.String())
+	if s == "" {
+		return res, "invalid query"
+	}
+
+	results := pkgdocSearch(m.Env, s, m.Limit, m.sig)
+	if m.sig.cancelled() {
+		return res, errCancelled
+	}
+
+	res["results"] = results
+	return res, ""
+}
+
 func (m *mPkgdoc) Call() (interface{}, string) {
-	if m.Q != "" {
+	switch {
+	case m.Local && m.Q != "":
+		return mPkgdocLocalSearch(m)
+	case m.Local:
+		return mPkgdocLocalDoc(m)
+	case m.Q != "":
 		return mPkgdocSearch(m)
 	}
 	return mPkgdocFetchDoc(m)
@@ -109,6 +161,11 @@ func (m *mPkgdoc) Call() (interface{}, string) {
 
 func init() {
 	registry.Register("pkgdoc", func(b *Broker) Caller {
-		return &mPkgdoc{}
+		return &mPkgdoc{
+			Env:          map[string]string{},
+			Limit:        50,
+			Width:        80,
+			remoteConfig: pkgdocRemote,
+		}
 	})
 }
@@ -8,10 +8,12 @@ import (
 type mPkgPaths struct {
 	Env     map[string]string
 	Exclude []string
+
+	cancelable
 }
 
 func (m *mPkgPaths) Call() (interface{}, string) {
-	return mPkgPathsRes(m.Env, m.Exclude), ""
+	return mPkgPathsRes(m.Env, m.Exclude, m.sig), ""
 }
 
 func init() {
@@ -20,7 +22,7 @@ func init() {
 	})
 }
 
-func mPkgPathsRes(env map[string]string, exclude []string) map[string]map[string]string {
+func mPkgPathsRes(env map[string]string, exclude []string, sig *cancelSignal) map[string]map[string]string {
 	lck := sync.Mutex{}
 	goroot, gopaths := envRootList(env)
 	res := map[string]map[string]string{}
@@ -31,7 +33,7 @@ func mPkgPathsRes(env map[string]string, exclude []string) map[string]map[string
 		go func() {
 			defer wg.Done()
 
-			paths := pkgPaths(srcDir, exclude)
+			paths := pkgPaths(env, srcDir, exclude, sig)
 			if len(paths) > 0 {
 				lck.Lock()
 				res[srcDir] = paths
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"go/build"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -18,10 +19,10 @@ type mPlay struct {
 	Env       map[string]string `json:"env"`
 	Cid       string            `json:"cid"`
 	BuildOnly bool              `json:"build_only"`
+	Stream    bool              `json:"stream"`
 	b         *Broker
 }
 
-// todo: send the client output as it comes
 func (m *mPlay) Call() (interface{}, string) {
 	env := envSlice(m.Env)
 	dir, err := ioutil.TempDir(tempDir(m.Env), "play-")
@@ -57,6 +58,18 @@ func (m *mPlay) Call() (interface{}, string) {
 	res := M{}
 	stdErr := bytes.NewBuffer(nil)
 	stdOut := bytes.NewBuffer(nil)
+
+	// the build and run steps share a single stream, its final frame reports on the last command run
+	var st *outStream
+	var lastCmd *exec.Cmd
+	var lastErr error
+	if m.Stream {
+		st = newOutStream(m.Cid)
+		defer func() {
+			st.end(lastCmd, lastErr)
+		}()
+	}
+
 	runCmd := func(name string, args ...string) (M, error) {
 		start := time.Now()
 		stdOut.Reset()
@@ -64,6 +77,10 @@ func (m *mPlay) Call() (interface{}, string) {
 		c := exec.Command(name, args...)
 		c.Stdout = stdOut
 		c.Stderr = stdErr
+		if st != nil {
+			c.Stdout = io.MultiWriter(stdOut, st.writer("out"))
+			c.Stderr = io.MultiWriter(stdErr, st.writer("err"))
+		}
 		c.Dir = m.Dir
 		c.Env = env
 
@@ -71,6 +88,7 @@ func (m *mPlay) Call() (interface{}, string) {
 		defer unwatchCmd(m.Cid)
 
 		err := c.Run()
+		lastCmd, lastErr = c, err
 		res := M{
 			"tmpFn": tmpFn,
 			"fn":    m.Fn,
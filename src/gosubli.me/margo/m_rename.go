@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"unicode"
+
+	"gosubli.me/something-borrowed/types"
+)
+
+type mRename struct {
+	Fn     string
+	Src    interface{}
+	Env    map[string]string
+	Offset int
+	Name   string
+
+	cancelable
+}
+
+type typesPkg struct {
+	pkg  *types.Package
+	info *types.Info
+}
+
+func newTypesInfo() *types.Info {
+	return &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Scopes:     map[ast.Node]*types.Scope{},
+	}
+}
+
+// importInfo (re-)imports the package at importPath, recording its type information
+func (w *PkgWalker) importInfo(importPath string) (typesPkg, bool) {
+	delete(w.imported, importPath)
+	conf := &PkgConfig{
+		AllowBinary:   true,
+		WithTestFiles: true,
+		Info:          newTypesInfo(),
+	}
+	pkg, _ := w.Import("", importPath, conf)
+	return typesPkg{pkg: pkg, info: conf.Info}, pkg != nil
+}
+
+// workspacePkgs type-checks the package of the file under the cursor along with the defining package of
+// the object under the cursor and, if it's exported, every package in srcDirs that imports the latter
+func (w *PkgWalker) workspacePkgs(cursor *FileCursor, srcDirs []string) (obj types.Object, pkgs []typesPkg, err error) {
+	conf := &PkgConfig{
+		AllowBinary:   true,
+		WithTestFiles: true,
+		Cursor:        cursor,
+		Info:          newTypesInfo(),
+	}
+	pkg, err := w.Import("", cursor.fileDir, conf)
+	if pkg == nil {
+		return nil, nil, err
+	}
+
+	obj, _ = w.LookupCursorObject(conf.Info, cursor)
+	if obj == nil {
+		return nil, nil, fmt.Errorf("no object found at the cursor")
+	}
+	if obj.Pkg() == nil {
+		return nil, nil, fmt.Errorf("%s is a builtin", obj.Name())
+	}
+
+	pkgs = []typesPkg{{pkg: pkg, info: conf.Info}}
+	defPath := obj.Pkg().Path()
+	if defPath != pkg.Path() {
+		if p, ok := w.importInfo(defPath); ok {
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	if !ast.IsExported(obj.Name()) {
+		return obj, pkgs, nil
+	}
+
+	for _, p := range findImporters(srcDirs, defPath, w.sig) {
+		if p == pkg.Path() || p == defPath {
+			continue
+		}
+		if w.sig.cancelled() {
+			return nil, nil, cancelledErr
+		}
+		if p, ok := w.importInfo(p); ok {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return obj, pkgs, nil
+}
+
+func (m *mRename) Call() (interface{}, string) {
+	res := M{}
+	if !isIdentifier(m.Name) {
+		return res, fmt.Sprintf("invalid name: %q", m.Name)
+	}
+
+	ctx := buildContext(m.Env)
+	w := NewPkgWalker(ctx, false, false, false)
+	w.sig = m.sig
+	cursor := &FileCursor{
+		src:       m.Src,
+		cursorPos: m.Offset,
+		fileName:  filepath.Base(m.Fn),
+		fileDir:   filepath.Dir(m.Fn),
+	}
+
+	target, pkgs, err := w.workspacePkgs(cursor, gopathSrcDirs(ctx))
+	if err != nil {
+		return res, err.Error()
+	}
+
+	switch target.(type) {
+	case *types.PkgName, *types.Label:
+		return res, fmt.Sprintf("renaming %s is not supported", target.Name())
+	}
+	if w.isBinaryPkg(target.Pkg().Path()) {
+		return res, fmt.Sprintf("cannot rename %s, it's declared in the standard library", target.Name())
+	}
+	if target.Name() == m.Name {
+		return res, ""
+	}
+
+	r := &renamer{
+		objRefs: objRefs{
+			w:      w,
+			target: target,
+			pkgs:   pkgs,
+		},
+		name: m.Name,
+	}
+	r.resolveTarget()
+	edits := r.edits()
+	conflicts := r.conflicts()
+
+	res["files"] = edits
+	res["conflicts"] = conflicts
+	if len(conflicts) > 0 {
+		return res, "rename conflicts: " + conflicts[0]
+	}
+	return res, ""
+}
+
+func init() {
+	registry.Register("rename", func(_ *Broker) Caller {
+		return &mRename{
+			Env: map[string]string{},
+		}
+	})
+}
+
+// objRefs finds the references to target in pkgs
+type objRefs struct {
+	w      *PkgWalker
+	target types.Object
+	pkgs   []typesPkg
+}
+
+type renamer struct {
+	objRefs
+	name string
+}
+
+// resolveTarget replaces the target with the object created when its package was type-checked with info,
+// so its scope is the one recorded in the package's info
+func (r *objRefs) resolveTarget() {
+	for _, p := range r.pkgs {
+		if p.pkg.Path() != r.target.Pkg().Path() {
+			continue
+		}
+		for _, obj := range p.info.Defs {
+			if obj != nil && obj.Pos() == r.target.Pos() && obj.Name() == r.target.Name() {
+				r.target = obj
+				return
+			}
+		}
+	}
+}
+
+// isRef returns true if obj refers to the object being renamed, this includes anonymous fields of the renamed type
+func (r *objRefs) isRef(obj types.Object) bool {
+	if obj == nil {
+		return false
+	}
+	// objects are matched by position because packages are type-checked more than once
+	if obj.Pos() == r.target.Pos() && obj.Name() == r.target.Name() {
+		return true
+	}
+	if v, ok := obj.(*types.Var); ok && v.Anonymous() {
+		if named, ok := derefType(v.Type()).(*types.Named); ok {
+			return r.isRef(named.Obj())
+		}
+	}
+	return false
+}
+
+// refs calls f for each identifier that refers to the renamed object
+func (r *objRefs) refs(f func(p typesPkg, id *ast.Ident)) {
+	seen := map[token.Pos]bool{}
+	for _, p := range r.pkgs {
+		for _, m := range []map[*ast.Ident]types.Object{p.info.Defs, p.info.Uses} {
+			for id, obj := range m {
+				if !seen[id.Pos()] && r.isRef(obj) {
+					seen[id.Pos()] = true
+					f(p, id)
+				}
+			}
+		}
+	}
+}
+
+func (r *renamer) edits() map[string][]textEdit {
+	edits := map[string][]textEdit{}
+	r.refs(func(_ typesPkg, id *ast.Ident) {
+		fn := r.w.fset.Position(id.Pos()).Filename
+		edits[fn] = append(edits[fn], posEdit(r.w.fset, id.Pos(), id.End(), r.name))
+	})
+	for _, l := range edits {
+		sort.Sort(textEdits(l))
+	}
+	return edits
+}
+
+func (r *renamer) conflicts() []string {
+	conflicts := []string{}
+	conflict := func(pos token.Pos, format string, a ...interface{}) {
+		s := fmt.Sprintf(format, a...)
+		if p := r.w.fset.Position(pos); p.IsValid() {
+			s = fmt.Sprintf("%s:%d:%d: %s", p.Filename, p.Line, p.Column, s)
+		}
+		conflicts = append(conflicts, s)
+	}
+
+	if ast.IsExported(r.target.Name()) && !ast.IsExported(r.name) {
+		r.refs(func(p typesPkg, id *ast.Ident) {
+			if p.pkg.Path() != r.target.Pkg().Path() {
+				conflict(id.Pos(), "renaming %s to %s would make it inaccessible from package %s", r.target.Name(), r.name, p.pkg.Path())
+			}
+		})
+	}
+
+	if r.target.Parent() != nil {
+		r.lexicalConflicts(conflict)
+	} else {
+		r.memberConflicts(conflict)
+	}
+
+	return conflicts
+}
+
+// lexicalConflicts checks for declarations in the same scope and references that would be shadowed or captured
+func (r *renamer) lexicalConflicts(conflict func(pos token.Pos, format string, a ...interface{})) {
+	scope := r.target.Parent()
+	if obj := scope.Lookup(r.name); obj != nil {
+		conflict(obj.Pos(), "%s is already declared in this block", r.name)
+	}
+
+	for _, p := range r.pkgs {
+		spans := scopeSpans(p.info)
+
+		// imports are declared in the file scope
+		if scope == r.target.Pkg().Scope() && p.pkg.Path() == r.target.Pkg().Path() {
+			for node, s := range p.info.Scopes {
+				if _, ok := node.(*ast.File); ok {
+					if obj := s.Lookup(r.name); obj != nil {
+						conflict(obj.Pos(), "%s conflicts with the import %s", r.name, obj.Name())
+					}
+				}
+			}
+		}
+
+		// references to the renamed object that would resolve to a different object
+		r.refs(func(rp typesPkg, id *ast.Ident) {
+			if rp.info != p.info {
+				return
+			}
+			s := innermostScope(spans, id.Pos())
+			if !scopeWithin(s, scope) {
+				return
+			}
+			for ; s != nil && s != scope; s = s.Parent() {
+				if obj := s.Lookup(r.name); obj != nil {
+					conflict(id.Pos(), "reference to %s would be shadowed by %s declared at %s", r.target.Name(), r.name, r.w.fset.Position(obj.Pos()))
+					return
+				}
+			}
+		})
+
+		// references to other objects that would resolve to the renamed object.
+		// local objects are only in scope after they're declared
+		local := scope != r.target.Pkg().Scope()
+		for id, obj := range p.info.Uses {
+			if id.Name != r.name || obj == nil || obj.Parent() == nil || r.isRef(obj) {
+				continue
+			}
+			if local && id.Pos() < r.target.Pos() {
+				continue
+			}
+			s := innermostScope(spans, id.Pos())
+			if scopeWithin(s, scope) && obj.Parent() != scope && scopeWithin(scope, obj.Parent()) {
+				conflict(id.Pos(), "reference to %s would refer to the renamed %s", r.name, r.target.Name())
+			}
+		}
+	}
+}
+
+// memberConflicts checks fields and methods for duplicates and broken interface implementations
+func (r *renamer) memberConflicts(conflict func(pos token.Pos, format string, a ...interface{})) {
+	var recv types.Type
+	switch t := r.target.(type) {
+	case *types.Func:
+		if sig, ok := t.Type().(*types.Signature); ok && sig.Recv() != nil {
+			recv = sig.Recv().Type()
+		}
+	case *types.Var:
+		recv = r.fieldOwner(t)
+	}
+	if recv == nil {
+		return
+	}
+
+	if obj, _, _ := types.LookupFieldOrMethod(recv, true, r.target.Pkg(), r.name); obj != nil {
+		conflict(obj.Pos(), "%s already has a field or method named %s", recv, r.name)
+	}
+
+	fn, ok := r.target.(*types.Func)
+	if !ok {
+		return
+	}
+
+	for _, p := range r.pkgs {
+		for _, obj := range p.info.Defs {
+			tn, ok := obj.(*types.TypeName)
+			if !ok {
+				continue
+			}
+
+			if iface, ok := recv.Underlying().(*types.Interface); ok {
+				// renaming an interface method breaks all its implementations
+				if _, ok := tn.Type().Underlying().(*types.Interface); !ok && implements(tn.Type(), iface) {
+					conflict(tn.Pos(), "renaming %s would break the implementation of %s by %s", fn.Name(), recv, tn.Name())
+				}
+			} else if iface, ok := tn.Type().Underlying().(*types.Interface); ok {
+				// renaming a method breaks the implementation of the interfaces that require it
+				if hasMethod(iface, fn.Name()) && implements(derefType(recv), iface) {
+					conflict(tn.Pos(), "renaming %s would break the implementation of %s by %s", fn.Name(), tn.Name(), recv)
+				}
+			}
+		}
+	}
+}
+
+// fieldOwner returns the named struct type in which the field v is declared
+func (r *renamer) fieldOwner(v *types.Var) types.Type {
+	for _, p := range r.pkgs {
+		for _, obj := range p.info.Defs {
+			if tn, ok := obj.(*types.TypeName); ok {
+				if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+					for i := 0; i < st.NumFields(); i++ {
+						if st.Field(i).Pos() == v.Pos() {
+							return tn.Type()
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func implements(t types.Type, iface *types.Interface) bool {
+	return types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+func hasMethod(iface *types.Interface, name string) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func derefType(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// scopeSpans returns the source range of each scope in info.
+// function scopes are associated with the *ast.FuncType but they cover the whole function, including its body
+func scopeSpans(info *types.Info) map[*types.Scope][2]token.Pos {
+	spans := map[*types.Scope][2]token.Pos{}
+	for node, s := range info.Scopes {
+		switch n := node.(type) {
+		case *ast.FuncType:
+		case *ast.File:
+			spans[s] = [2]token.Pos{n.Pos(), n.End()}
+			ast.Inspect(n, func(node ast.Node) bool {
+				var ft *ast.FuncType
+				switch x := node.(type) {
+				case *ast.FuncDecl:
+					ft = x.Type
+				case *ast.FuncLit:
+					ft = x.Type
+				}
+				if fs := info.Scopes[ft]; ft != nil && fs != nil {
+					spans[fs] = [2]token.Pos{node.Pos(), node.End()}
+				}
+				return true
+			})
+		default:
+			spans[s] = [2]token.Pos{n.Pos(), n.End()}
+		}
+	}
+	return spans
+}
+
+// innermostScope returns the smallest scope in spans that contains pos
+func innermostScope(spans map[*types.Scope][2]token.Pos, pos token.Pos) *types.Scope {
+	var scope *types.Scope
+	var span [2]token.Pos
+	for s, sp := range spans {
+		if pos >= sp[0] && pos <= sp[1] && (scope == nil || sp[1]-sp[0] < span[1]-span[0]) {
+			scope, span = s, sp
+		}
+	}
+	return scope
+}
+
+// scopeWithin returns true if s is, or is nested inside, parent
+func scopeWithin(s, parent *types.Scope) bool {
+	for ; s != nil; s = s.Parent() {
+		if s == parent {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || s == "_" || token.Lookup(s).IsKeyword() {
+		return false
+	}
+	for i, c := range s {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
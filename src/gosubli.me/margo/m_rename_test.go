@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixtureEnv returns an environment whose GOPATH is the fixture workspace in testing/testdata
+func fixtureEnv() map[string]string {
+	wd, _ := os.Getwd()
+	return map[string]string{
+		"GOPATH": filepath.Join(wd, "testing", "testdata"),
+	}
+}
+
+// fixtureFile returns the absolute name of the file fn in the fixture workspace, e.g. ex/a/a.go
+func fixtureFile(fn string) string {
+	return filepath.Join(fixtureEnv()["GOPATH"], "src", filepath.FromSlash(fn))
+}
+
+// fixtureOffset returns the byte offset of the first occurrence of at in the fixture file fn.
+// the offset is moved to the | in at if it contains one
+func fixtureOffset(t *testing.T, fn, at string) int {
+	src, err := ioutil.ReadFile(fixtureFile(fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := strings.Index(at, "|")
+	if i < 0 {
+		i = 0
+	} else {
+		at = at[:i] + at[i+1:]
+	}
+	n := strings.Index(string(src), at)
+	if n < 0 {
+		t.Fatalf("%s not found in %s", at, fn)
+	}
+	return n + i
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		at   string
+		to   string
+		// edits is the number of edits in each file, by base name
+		edits map[string]int
+		// conflict is a substring of the first conflict, if any
+		conflict string
+	}{
+		{
+			name:  "field referenced from an importer",
+			fn:    "ex/a/a.go",
+			at:    "Val  int",
+			to:    "Value",
+			edits: map[string]int{"a.go": 2, "b.go": 1},
+		},
+		{
+			name:  "field from the importer",
+			fn:    "ex/b/b.go",
+			at:    "t.|Val +",
+			to:    "Value",
+			edits: map[string]int{"a.go": 2, "b.go": 1},
+		},
+		{
+			name:  "func",
+			fn:    "ex/a/a.go",
+			at:    "New(v",
+			to:    "Make",
+			edits: map[string]int{"a.go": 1, "b.go": 1},
+		},
+		{
+			name:     "unexported",
+			fn:       "ex/a/a.go",
+			at:       "Val  int",
+			to:       "val",
+			conflict: "would make it inaccessible from package ex/b",
+		},
+		{
+			name:     "duplicate declaration",
+			fn:       "ex/a/a.go",
+			at:       "New(v",
+			to:       "T",
+			conflict: "T is already declared in this block",
+		},
+		{
+			name:     "duplicate field",
+			fn:       "ex/a/a.go",
+			at:       "Val  int",
+			to:       "Size",
+			conflict: "already has a field or method named Size",
+		},
+		{
+			name:     "shadowed reference",
+			fn:       "ex/a/a.go",
+			at:       "x := v",
+			to:       "y",
+			conflict: "reference to x would be shadowed by y",
+		},
+		{
+			name:     "captured reference",
+			fn:       "ex/a/a.go",
+			at:       "y := 1",
+			to:       "x",
+			conflict: "reference to x would refer to the renamed y",
+		},
+		{
+			name:     "method of an implementation",
+			fn:       "ex/a/a.go",
+			at:       "(t *T) |Name",
+			to:       "Title",
+			conflict: "would break the implementation of Namer by *ex/a.T",
+		},
+		{
+			name:     "method of an interface",
+			fn:       "ex/a/a.go",
+			at:       "\t|Name() string",
+			to:       "Title",
+			conflict: "would break the implementation of ex/a.Namer by T",
+		},
+	}
+
+	for _, tt := range tests {
+		m := &mRename{
+			Fn:     fixtureFile(tt.fn),
+			Env:    fixtureEnv(),
+			Offset: fixtureOffset(t, tt.fn, tt.at),
+			Name:   tt.to,
+		}
+		res, err := m.Call()
+
+		if tt.conflict != "" {
+			assert.Equal(t, true, strings.Contains(err, tt.conflict), tt.name+": "+err)
+			continue
+		}
+		assert.Equal(t, "", err, tt.name)
+
+		edits := map[string]int{}
+		for fn, l := range res.(M)["files"].(map[string][]textEdit) {
+			edits[filepath.Base(fn)] = len(l)
+		}
+		assert.Equal(t, tt.edits, edits, tt.name)
+	}
+}
+
+func TestRenameInvalid(t *testing.T) {
+	for _, name := range []string{"", "_", "func", "1a", "a-b"} {
+		m := &mRename{
+			Fn:     fixtureFile("ex/a/a.go"),
+			Env:    fixtureEnv(),
+			Offset: fixtureOffset(t, "ex/a/a.go", "New(v"),
+			Name:   name,
+		}
+		_, err := m.Call()
+		assert.Equal(t, true, strings.HasPrefix(err, "invalid name"), name)
+	}
+}
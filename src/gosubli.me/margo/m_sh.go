@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"io"
 	"os/exec"
 	"strings"
 	"time"
@@ -20,10 +21,13 @@ type mSh struct {
 	Cmd mShCmd
 	Cid string
 	Cwd string
+
+	// if Stream is true, output is also sent to the client as it comes, see outStream
+	Stream bool
 }
 
-// todo: send the client output as it comes
-//       handle And, Or
+// Call runs m.Cmd followed by its chain of commands: the And command is run if the previous command
+// succeeded and the Or command is run if it failed. all steps share m.Cid so killing it stops the chain
 func (m *mSh) Call() (interface{}, string) {
 	env := envSlice(m.Env)
 
@@ -36,23 +40,80 @@ func (m *mSh) Call() (interface{}, string) {
 	start := time.Now()
 	stdErr := bytes.NewBuffer(nil)
 	stdOut := bytes.NewBuffer(nil)
-	c := exec.Command(m.Cmd.Name, m.Cmd.Args...)
-	c.Stdout = stdOut
-	c.Stderr = stdErr
-	if m.Cmd.Input != "" {
-		c.Stdin = strings.NewReader(m.Cmd.Input)
+	var st *outStream
+	if m.Stream {
+		st = newOutStream(m.Cid)
+	}
+
+	// the cid is registered for the whole chain so that a kill between two steps stops it too
+	chain := watchChain(m.Cid)
+	if chain != nil {
+		defer unwatchCmd(m.Cid)
 	}
-	c.Dir = m.Cwd
-	c.Env = env
 
-	watchCmd(m.Cid, c)
-	err := c.Run()
-	unwatchCmd(m.Cid)
+	steps := []M{}
+	var c *exec.Cmd
+	var err error
+	for sc := &m.Cmd; sc != nil && !chain.isKilled(); {
+		stepStart := time.Now()
+		stepErr := bytes.NewBuffer(nil)
+		stepOut := bytes.NewBuffer(nil)
+		stepCmd := exec.Command(sc.Name, sc.Args...)
+		stepCmd.Stdout = io.MultiWriter(stdOut, stepOut)
+		stepCmd.Stderr = io.MultiWriter(stdErr, stepErr)
+		if st != nil {
+			stepCmd.Stdout = io.MultiWriter(stdOut, stepOut, st.writer("out"))
+			stepCmd.Stderr = io.MultiWriter(stdErr, stepErr, st.writer("err"))
+		}
+		if sc.Input != "" {
+			stepCmd.Stdin = strings.NewReader(sc.Input)
+		}
+		stepCmd.Dir = m.Cwd
+		stepCmd.Env = env
+
+		// the chain was killed before this step started, so there's nothing to record
+		if err = chain.start(stepCmd); err == errCmdKilled {
+			break
+		}
+		c = stepCmd
+		if err == nil {
+			err = c.Wait()
+		}
+		killed := chain.isKilled()
+
+		steps = append(steps, M{
+			"name":  sc.Name,
+			"args":  sc.Args,
+			"out":   jData(stepOut.Bytes()),
+			"err":   jData(stepErr.Bytes()),
+			"exit":  cmdExitStatus(c),
+			"error": errStr(err),
+			"dur":   time.Now().Sub(stepStart).String(),
+		})
+
+		switch {
+		case killed:
+			sc = nil
+		case err == nil:
+			sc = sc.And
+		default:
+			sc = sc.Or
+		}
+	}
+
+	if err == nil && chain.isKilled() {
+		err = errCmdKilled
+	}
+
+	if st != nil {
+		st.end(c, err)
+	}
 
 	res := M{
-		"out": jData(stdOut.Bytes()),
-		"err": jData(stdErr.Bytes()),
-		"dur": time.Now().Sub(start).String(),
+		"out":   jData(stdOut.Bytes()),
+		"err":   jData(stdErr.Bytes()),
+		"dur":   time.Now().Sub(start).String(),
+		"steps": steps,
 	}
 	return res, errStr(err)
 }
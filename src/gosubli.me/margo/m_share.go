@@ -8,9 +8,12 @@ import (
 
 type mShare struct {
 	Src string
+
+	remoteConfig
+	cancelable
 }
 
-func (m mShare) Call() (interface{}, string) {
+func (m *mShare) Call() (interface{}, string) {
 	res := M{}
 
 	s := bytes.TrimSpace([]byte(m.Src))
@@ -18,11 +21,14 @@ func (m mShare) Call() (interface{}, string) {
 		return res, "Nothing to share"
 	}
 
-	u := "http://play.golang.org"
+	u := m.baseURL()
 	body := bytes.NewBufferString(m.Src)
 	req, err := http.NewRequest("POST", u+"/share", body)
+	if err != nil {
+		return res, err.Error()
+	}
 	req.Header.Set("User-Agent", "GoSublime")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.do(nil, m.sig, req)
 	if err != nil {
 		return res, err.Error()
 	}
@@ -44,6 +50,8 @@ func (m mShare) Call() (interface{}, string) {
 
 func init() {
 	registry.Register("share", func(_ *Broker) Caller {
-		return &mShare{}
+		return &mShare{
+			remoteConfig: shareRemote,
+		}
 	})
 }
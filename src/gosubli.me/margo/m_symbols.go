@@ -0,0 +1,133 @@
+package main
+
+import (
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gosubli.me/something-borrowed/gocode"
+)
+
+type mSymbols struct {
+	Query string
+	Env   map[string]string
+	Limit int
+
+	cancelable
+}
+
+type mSymbolsSym struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Exported   bool   `json:"exported"`
+	Recv       string `json:"recv"`
+	Pkg        string `json:"pkg"`
+	ImportPath string `json:"import_path"`
+	Fn         string `json:"fn"`
+	Row        int    `json:"row"`
+	Col        int    `json:"col"`
+	Score      int    `json:"score"`
+}
+
+type mSymbolsSyms []*mSymbolsSym
+
+func (l mSymbolsSyms) Len() int {
+	return len(l)
+}
+
+func (l mSymbolsSyms) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	switch {
+	case a.Score != b.Score:
+		return a.Score > b.Score
+	case a.Exported != b.Exported:
+		return a.Exported
+	case len(a.ImportPath) != len(b.ImportPath):
+		return len(a.ImportPath) < len(b.ImportPath)
+	case a.ImportPath != b.ImportPath:
+		return a.ImportPath < b.ImportPath
+	}
+	return a.Name < b.Name
+}
+
+func (l mSymbolsSyms) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (m *mSymbols) Call() (interface{}, string) {
+	syms := mSymbolsSyms{}
+	if m.Query == "" {
+		return M{"symbols": syms}, ""
+	}
+
+	// `pkg.Name` and `Type.Method` queries are matched against the qualified name
+	qualified := strings.Contains(m.Query, ".")
+	for _, root := range rootDirs(m.Env) {
+		pkgIdx.walk(m.Env, root, true, m.sig, func(dir string, d *idxDir) {
+			importPath, err := filepath.Rel(root, dir)
+			if err != nil || importPath == "." {
+				return
+			}
+			importPath = filepath.ToSlash(importPath)
+			if contains(strings.Split(importPath, "/"), "testdata") {
+				return
+			}
+
+			for nm, f := range d.Files {
+				if f.Ignore || strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+					continue
+				}
+
+				for _, decl := range f.Decls {
+					s := decl.Name
+					recv := strings.TrimLeft(decl.Recv, "*")
+					if qualified {
+						if recv != "" {
+							s = recv + "." + s
+						} else {
+							s = f.Pkg + "." + s
+						}
+					}
+
+					score, ok := gocode.FuzzyMatch(m.Query, s)
+					if !ok {
+						continue
+					}
+
+					syms = append(syms, &mSymbolsSym{
+						Name:       decl.Name,
+						Kind:       decl.Kind,
+						Exported:   ast.IsExported(decl.Name),
+						Recv:       decl.Recv,
+						Pkg:        f.Pkg,
+						ImportPath: importPath,
+						Fn:         filepath.Join(dir, nm),
+						Row:        decl.Row,
+						Col:        decl.Col,
+						Score:      score,
+					})
+				}
+			}
+		})
+	}
+
+	if m.sig.cancelled() {
+		return M{}, errCancelled
+	}
+
+	sort.Sort(syms)
+	if m.Limit > 0 && len(syms) > m.Limit {
+		syms = syms[:m.Limit]
+	}
+	return M{"symbols": syms}, ""
+}
+
+func init() {
+	registry.Register("symbols", func(_ *Broker) Caller {
+		return &mSymbols{
+			Env:   map[string]string{},
+			Limit: 100,
+		}
+	})
+}
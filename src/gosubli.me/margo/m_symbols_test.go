@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbols(t *testing.T) {
+	env := fixtureEnv()
+	// keep the standard library out of it
+	env["GOROOT"] = filepath.Join(env["GOPATH"], "goroot")
+
+	// each symbol is described as `kind import/path.Name recv`
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// equal scores are ordered by the length of the import path
+		{"New", []string{"func ex/a.New ", "func ex/doc.New "}},
+		// queries with a `.` are matched against the name qualified by the package or the receiver type
+		{"a.New", []string{"func ex/a.New "}},
+		{"T.Name", []string{"func ex/a.Name *T"}},
+		// the closer matches, i.e. the shorter names, are ranked first
+		{"nam", []string{"func ex/a.Name *T", "func ex/c.Name Named", "type ex/a.Namer ", "type ex/c.Named "}},
+		{"xyz", []string{}},
+	}
+	for _, tt := range tests {
+		res, err := (&mSymbols{Query: tt.query, Env: env}).Call()
+		assert.Equal(t, "", err, tt.query)
+
+		got := []string{}
+		for _, s := range res.(M)["symbols"].(mSymbolsSyms) {
+			got = append(got, fmt.Sprintf("%s %s.%s %s", s.Kind, s.ImportPath, s.Name, s.Recv))
+		}
+		assert.Equal(t, tt.want, got, tt.query)
+	}
+
+	res, _ := (&mSymbols{Query: "nam", Env: env, Limit: 1}).Call()
+	assert.Equal(t, 1, len(res.(M)["symbols"].(mSymbolsSyms)), "limit")
+}
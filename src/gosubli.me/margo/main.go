@@ -70,6 +70,8 @@ func main() {
 	maxMemDefault := 1000
 	maxMem := 0
 	tag := ""
+	remote := remoteConfig{Timeout: remoteTimeoutDefault}
+	remoteMock := false
 	flags := flag.NewFlagSet("MarGo", flag.ExitOnError)
 	flags.BoolVar(&dump_env, "env", dump_env, "if true, dump all environment variables as a json map to stdout and exit")
 	flags.BoolVar(&wait, "wait", wait, "Whether or not to wait for outstanding requests (which may be hanging forever) when exiting")
@@ -77,8 +79,22 @@ func main() {
 	flags.StringVar(&do, "do", "-", "Process the specified operations(lines) and exit. `-` means operate as normal (`-do` implies `-wait=true`)")
 	flags.StringVar(&tag, "tag", tag, "Requests will include a member `tag' with this value")
 	flags.IntVar(&maxMem, "oom", maxMemDefault, "The maximum amount of memory MarGo is allowed to use. If memory use reaches this value, MarGo dies :'(")
+	flags.StringVar(&shareRemote.URL, "share-url", shareRemote.URL, "The base URL of the playground used by `share`")
+	flags.StringVar(&pkgdocRemote.URL, "pkgdoc-url", pkgdocRemote.URL, "The base URL of the godoc.org instance used by `pkgdoc`")
+	flags.IntVar(&remote.Timeout, "http-timeout", remote.Timeout, "The timeout, in milliseconds, of requests to the playground and godoc.org. 0 means no timeout")
+	flags.StringVar(&remote.Proxy, "http-proxy", remote.Proxy, "The URL of the HTTP proxy used to reach the playground and godoc.org, instead of the one set in the environment")
+	flags.BoolVar(&remote.Insecure, "http-insecure", remote.Insecure, "If true, the TLS certificates of the playground and godoc.org are not verified")
+	flags.StringVar(&remote.CAFile, "http-ca-file", remote.CAFile, "A PEM file of additional certificates to trust when connecting to the playground and godoc.org")
+	flags.BoolVar(&remoteMock, "remote-mock", remoteMock, "For testing: if true, `share` and `pkgdoc` are served by a built-in stand-in for the playground and godoc.org instead of the network. the shared URLs can't be opened in a browser")
 	flags.Parse(os.Args[1:])
 
+	for _, r := range []*remoteConfig{&shareRemote, &pkgdocRemote} {
+		r.Timeout, r.Proxy, r.Insecure, r.CAFile = remote.Timeout, remote.Proxy, remote.Insecure, remote.CAFile
+		if remoteMock {
+			r.URL = remoteMockURL
+		}
+	}
+
 	// 4 is arbitrary,
 	runtime.GOMAXPROCS(runtime.NumCPU() + 4)
 
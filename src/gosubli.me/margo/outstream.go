@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// outStreamToken is the token used for all output frames, the client identifies the command via `cid`
+	outStreamToken = "margo.output"
+)
+
+// outStream sends the output of commands to the client as it comes.
+// frames are numbered in the order they are sent so the client can re-assemble the output
+// even though stdout and stderr are written concurrently
+type outStream struct {
+	lck   sync.Mutex
+	cid   string
+	seq   uint64
+	start time.Time
+}
+
+type outStreamWriter struct {
+	s    *outStream
+	name string
+}
+
+func newOutStream(cid string) *outStream {
+	return &outStream{
+		cid:   cid,
+		start: time.Now(),
+	}
+}
+
+func (s *outStream) writer(name string) io.Writer {
+	return &outStreamWriter{
+		s:    s,
+		name: name,
+	}
+}
+
+func (s *outStream) send(name string, data M) {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	s.seq += 1
+	data["cid"] = s.cid
+	data["seq"] = s.seq
+	data["stream"] = name
+	post(Response{
+		Token: outStreamToken,
+		Data:  data,
+	})
+}
+
+// end sends the final frame. it should be called exactly once, after all commands have exited
+func (s *outStream) end(c *exec.Cmd, err error) {
+	s.send("status", M{
+		"done":  true,
+		"exit":  cmdExitStatus(c),
+		"error": errStr(err),
+		"dur":   time.Now().Sub(s.start).String(),
+	})
+}
+
+func (w *outStreamWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		// the caller is allowed to re-use p after we return
+		w.s.send(w.name, M{
+			"out": jData(append([]byte{}, p...)),
+		})
+	}
+	return len(p), nil
+}
+
+// cmdExitStatus returns the exit status of c or -1 if it didn't run to completion
+func cmdExitStatus(c *exec.Cmd) int {
+	if c == nil || c.ProcessState == nil {
+		return -1
+	}
+	if ws, ok := c.ProcessState.Sys().(syscall.WaitStatus); ok {
+		return ws.ExitStatus()
+	}
+	if c.ProcessState.Success() {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gosubli.me/something-borrowed/gocode"
+)
+
+// pkgdocPackage is the documentation of a package, as built from its source by go/doc
+type pkgdocPackage struct {
+	Name       string          `json:"name"`
+	ImportPath string          `json:"import_path"`
+	Dir        string          `json:"dir"`
+	Doc        string          `json:"doc"`
+	Consts     []pkgdocValue   `json:"consts"`
+	Vars       []pkgdocValue   `json:"vars"`
+	Funcs      []pkgdocFunc    `json:"funcs"`
+	Types      []pkgdocType    `json:"types"`
+	Examples   []pkgdocExample `json:"examples"`
+}
+
+// pkgdocValue is a const or var declaration, possibly declaring several names
+type pkgdocValue struct {
+	Names []string `json:"names"`
+	Decl  string   `json:"decl"`
+	Doc   string   `json:"doc"`
+}
+
+type pkgdocFunc struct {
+	Name string `json:"name"`
+	// Recv is the receiver type of methods e.g. `*T`
+	Recv     string          `json:"recv,omitempty"`
+	Decl     string          `json:"decl"`
+	Doc      string          `json:"doc"`
+	Examples []pkgdocExample `json:"examples"`
+}
+
+type pkgdocType struct {
+	Name   string        `json:"name"`
+	Decl   string        `json:"decl"`
+	Doc    string        `json:"doc"`
+	Consts []pkgdocValue `json:"consts"`
+	Vars   []pkgdocValue `json:"vars"`
+	// Funcs are the functions that return the type, usually its constructors
+	Funcs    []pkgdocFunc    `json:"funcs"`
+	Methods  []pkgdocFunc    `json:"methods"`
+	Examples []pkgdocExample `json:"examples"`
+}
+
+type pkgdocExample struct {
+	// Suffix distinguishes the examples of the same package, function, type or method
+	Suffix string `json:"suffix,omitempty"`
+	Doc    string `json:"doc"`
+	Code   string `json:"code"`
+	Output string `json:"output"`
+}
+
+// pkgdocBuild reads the documentation of the package at the import path or directory path
+func pkgdocBuild(ctx *build.Context, path string) (*pkgdocPackage, error) {
+	var bp *build.Package
+	var err error
+	if filepath.IsAbs(path) {
+		bp, err = ctx.ImportDir(path, build.ImportComment)
+	} else {
+		bp, err = ctx.Import(path, "", build.ImportComment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	parse := func(names []string) ([]*ast.File, error) {
+		files := []*ast.File{}
+		for _, nm := range names {
+			af, err := parser.ParseFile(fset, filepath.Join(bp.Dir, nm), nil, parser.ParseComments)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, af)
+		}
+		return files, nil
+	}
+
+	files, err := parse(append(append([]string{}, bp.GoFiles...), bp.CgoFiles...))
+	if err != nil {
+		return nil, err
+	}
+	// examples are only read from the test files that can be parsed, they don't affect the rest of the documentation
+	testFiles, _ := parse(append(append([]string{}, bp.TestGoFiles...), bp.XTestGoFiles...))
+
+	ap := &ast.Package{
+		Name:  bp.Name,
+		Files: map[string]*ast.File{},
+	}
+	for _, af := range files {
+		ap.Files[fset.Position(af.Pos()).Filename] = af
+	}
+	dp := doc.New(ap, bp.ImportPath, 0)
+
+	node := func(n interface{}) string {
+		var buf bytes.Buffer
+		cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+		cfg.Fprint(&buf, fset, n)
+		return buf.String()
+	}
+	values := func(l []*doc.Value) []pkgdocValue {
+		vl := []pkgdocValue{}
+		for _, v := range l {
+			vl = append(vl, pkgdocValue{Names: v.Names, Decl: node(v.Decl), Doc: v.Doc})
+		}
+		return vl
+	}
+	funcs := func(l []*doc.Func) []pkgdocFunc {
+		fl := []pkgdocFunc{}
+		for _, f := range l {
+			fl = append(fl, pkgdocFunc{
+				Name:     f.Name,
+				Recv:     f.Recv,
+				Decl:     node(f.Decl),
+				Doc:      f.Doc,
+				Examples: []pkgdocExample{},
+			})
+		}
+		return fl
+	}
+
+	p := &pkgdocPackage{
+		Name:       dp.Name,
+		ImportPath: dp.ImportPath,
+		Dir:        bp.Dir,
+		Doc:        dp.Doc,
+		Consts:     values(dp.Consts),
+		Vars:       values(dp.Vars),
+		Funcs:      funcs(dp.Funcs),
+		Types:      []pkgdocType{},
+		Examples:   []pkgdocExample{},
+	}
+	for _, t := range dp.Types {
+		p.Types = append(p.Types, pkgdocType{
+			Name:     t.Name,
+			Decl:     node(t.Decl),
+			Doc:      t.Doc,
+			Consts:   values(t.Consts),
+			Vars:     values(t.Vars),
+			Funcs:    funcs(t.Funcs),
+			Methods:  funcs(t.Methods),
+			Examples: []pkgdocExample{},
+		})
+	}
+
+	for _, ex := range doc.Examples(testFiles...) {
+		name, suffix := pkgdocExampleName(ex.Name)
+		e := pkgdocExample{
+			Suffix: suffix,
+			Doc:    ex.Doc,
+			Output: ex.Output,
+		}
+		// the output is already in Output, so its comment is left out of the code
+		comments := []*ast.CommentGroup{}
+		for _, cg := range ex.Comments {
+			if s := strings.ToLower(cg.Text()); !strings.HasPrefix(s, "output:") && !strings.HasPrefix(s, "unordered output:") {
+				comments = append(comments, cg)
+			}
+		}
+		e.Code = node(&printer.CommentedNode{Node: ex.Code, Comments: comments})
+		// like godoc, show the body of the example without the braces
+		if _, ok := ex.Code.(*ast.BlockStmt); ok {
+			e.Code = strings.TrimSuffix(strings.TrimPrefix(e.Code, "{\n"), "\n}")
+			e.Code = strings.Replace(strings.TrimPrefix(e.Code, "\t"), "\n\t", "\n", -1)
+		}
+		e.Code = strings.TrimRight(e.Code, " \t\n")
+		p.addExample(name, e)
+	}
+	return p, nil
+}
+
+// pkgdocExampleName splits the name of an example function, without the `Example` prefix, into the name it documents and its suffix.
+// like `go test`, the suffix starts with a lower-case letter, e.g. `T_M_suffix` is an example of the method T.M
+func pkgdocExampleName(s string) (name, suffix string) {
+	if i := strings.LastIndex(s, "_"); i >= 0 && i < len(s)-1 {
+		if c := s[i+1]; c >= 'a' && c <= 'z' {
+			return s[:i], s[i+1:]
+		}
+	}
+	if strings.HasPrefix(s, "_") {
+		return "", s[1:]
+	}
+	return s, ""
+}
+
+// addExample adds e to the package, function, type or method (named `T_M`) it documents.
+// examples of unknown names are added to the package
+func (p *pkgdocPackage) addExample(name string, e pkgdocExample) {
+	add := func(l []pkgdocFunc, name string) bool {
+		for i, f := range l {
+			if f.Name == name {
+				l[i].Examples = append(l[i].Examples, e)
+				return true
+			}
+		}
+		return false
+	}
+
+	if add(p.Funcs, name) {
+		return
+	}
+	typ, method := name, ""
+	if i := strings.Index(name, "_"); i >= 0 {
+		typ, method = name[:i], name[i+1:]
+	}
+	for i := range p.Types {
+		t := &p.Types[i]
+		switch {
+		case t.Name == name:
+			t.Examples = append(t.Examples, e)
+			return
+		case add(t.Funcs, name):
+			return
+		case t.Name == typ && add(t.Methods, method):
+			return
+		}
+	}
+	p.Examples = append(p.Examples, e)
+}
+
+// pkgdocText renders the documentation in the plain text format of `godoc`
+func pkgdocText(p *pkgdocPackage, width int) string {
+	const indent = "    "
+	buf := &bytes.Buffer{}
+	section := func(name string) {
+		fmt.Fprintf(buf, "%s\n\n", name)
+	}
+	comment := func(s string) {
+		if s != "" {
+			doc.ToText(buf, s, indent, indent+"\t", width-len(indent))
+		}
+	}
+	examples := func(l []pkgdocExample) {
+		for _, e := range l {
+			title := "Example"
+			if e.Suffix != "" {
+				title += " (" + e.Suffix + ")"
+			}
+			fmt.Fprintf(buf, "%s%s:\n", indent, title)
+			comment(e.Doc)
+			fmt.Fprintf(buf, "%s\n", indentText(e.Code, indent+"\t"))
+			if e.Output != "" {
+				fmt.Fprintf(buf, "%sOutput:\n%s\n", indent, indentText(strings.TrimSpace(e.Output), indent+"\t"))
+			}
+			buf.WriteString("\n")
+		}
+	}
+	values := func(l []pkgdocValue) {
+		for _, v := range l {
+			fmt.Fprintf(buf, "%s\n", v.Decl)
+			comment(v.Doc)
+			buf.WriteString("\n")
+		}
+	}
+	funcs := func(l []pkgdocFunc) {
+		for _, f := range l {
+			fmt.Fprintf(buf, "%s\n", f.Decl)
+			comment(f.Doc)
+			buf.WriteString("\n")
+			examples(f.Examples)
+		}
+	}
+
+	section("PACKAGE DOCUMENTATION")
+	fmt.Fprintf(buf, "package %s\n%simport \"%s\"\n\n", p.Name, indent, p.ImportPath)
+	if p.Doc != "" {
+		comment(p.Doc)
+		buf.WriteString("\n")
+	}
+	examples(p.Examples)
+
+	if len(p.Consts) != 0 {
+		section("CONSTANTS")
+		values(p.Consts)
+	}
+	if len(p.Vars) != 0 {
+		section("VARIABLES")
+		values(p.Vars)
+	}
+	if len(p.Funcs) != 0 {
+		section("FUNCTIONS")
+		funcs(p.Funcs)
+	}
+	if len(p.Types) != 0 {
+		section("TYPES")
+		for _, t := range p.Types {
+			fmt.Fprintf(buf, "%s\n", t.Decl)
+			comment(t.Doc)
+			buf.WriteString("\n")
+			examples(t.Examples)
+			values(t.Consts)
+			values(t.Vars)
+			funcs(t.Funcs)
+			funcs(t.Methods)
+		}
+	}
+	return strings.TrimSpace(buf.String()) + "\n"
+}
+
+func indentText(s, indent string) string {
+	return indent + strings.Replace(s, "\n", "\n"+indent, -1)
+}
+
+// pkgdocResult is a package found by pkgdocSearch
+type pkgdocResult struct {
+	path  string
+	dir   string
+	idx   *idxDir
+	score int
+}
+
+type pkgdocResults []pkgdocResult
+
+func (l pkgdocResults) Len() int {
+	return len(l)
+}
+
+func (l pkgdocResults) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	switch {
+	case a.score != b.score:
+		return a.score > b.score
+	case len(a.path) != len(b.path):
+		return len(a.path) < len(b.path)
+	}
+	return a.path < b.path
+}
+
+func (l pkgdocResults) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// pkgdocSearch looks for the packages in the package index whose import path matches q.
+// the results are the import paths and the synopsis of the packages
+func pkgdocSearch(env map[string]string, q string, limit int, sig *cancelSignal) []mPkgdocDoc {
+	results := pkgdocResults{}
+	for _, root := range rootDirs(env) {
+		pkgIdx.walk(env, root, true, sig, func(dir string, d *idxDir) {
+			importPath, err := filepath.Rel(root, dir)
+			if err != nil || importPath == "." {
+				return
+			}
+			importPath = filepath.ToSlash(importPath)
+			if contains(strings.Split(importPath, "/"), "testdata") || d.pkgName() == "" {
+				return
+			}
+
+			score, ok := gocode.FuzzyMatch(q, importPath)
+			if !ok {
+				return
+			}
+			// prefer packages named q, the way `import "q"` or `q.F` refer to them
+			if name := d.pkgName(); strings.EqualFold(name, q) || strings.EqualFold(filepath.Base(dir), q) {
+				score += 100
+			}
+			results = append(results, pkgdocResult{path: importPath, dir: dir, idx: d, score: score})
+		})
+	}
+
+	sort.Sort(results)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	l := make([]mPkgdocDoc, len(results))
+	for i, r := range results {
+		l[i] = mPkgdocDoc{Path: r.path, Doc: pkgdocSynopsis(r.dir, r.idx)}
+	}
+	return l
+}
+
+// pkgdocSynopsis returns the first sentence of the package documentation in dir.
+// like idxDir.pkgName, the test files and the files ignored by a build constraint or of another package are skipped
+func pkgdocSynopsis(dir string, d *idxDir) string {
+	pkg := d.pkgName()
+	for _, nm := range d.fileNames() {
+		f := d.Files[nm]
+		if f == nil || f.Pkg != pkg || f.Ignore || strings.HasSuffix(strings.ToLower(nm), "_test.go") {
+			continue
+		}
+		af, _ := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, nm), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if af != nil && af.Doc != nil {
+			return doc.Synopsis(af.Doc.Text())
+		}
+	}
+	return ""
+}
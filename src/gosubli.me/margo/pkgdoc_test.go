@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPkgdocExamples(t *testing.T) {
+	p := &pkgdocPackage{
+		Funcs: []pkgdocFunc{{Name: "F"}},
+		Types: []pkgdocType{{
+			Name:    "T",
+			Funcs:   []pkgdocFunc{{Name: "NewT"}},
+			Methods: []pkgdocFunc{{Name: "M"}},
+		}},
+	}
+	for _, s := range []string{"", "_second", "F", "F_second", "T", "NewT", "T_M", "T_M_second", "Unknown"} {
+		name, suffix := pkgdocExampleName(s)
+		p.addExample(name, pkgdocExample{Suffix: suffix})
+	}
+
+	suffixes := func(l []pkgdocExample) []string {
+		sl := []string{}
+		for _, e := range l {
+			sl = append(sl, e.Suffix)
+		}
+		return sl
+	}
+	assert.Equal(t, []string{"", "second", ""}, suffixes(p.Examples), "package")
+	assert.Equal(t, []string{"", "second"}, suffixes(p.Funcs[0].Examples), "F")
+	assert.Equal(t, []string{""}, suffixes(p.Types[0].Examples), "T")
+	assert.Equal(t, []string{""}, suffixes(p.Types[0].Funcs[0].Examples), "NewT")
+	assert.Equal(t, []string{"", "second"}, suffixes(p.Types[0].Methods[0].Examples), "T.M")
+}
+
+func TestPkgdocBuild(t *testing.T) {
+	p, err := pkgdocBuild(buildContext(fixtureEnv()), "ex/doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "doc", p.Name)
+	assert.Equal(t, "ex/doc", p.ImportPath)
+	assert.Equal(t, "Package doc is documented for the pkgdoc tests.\n", p.Doc)
+	assert.Equal(t, []pkgdocValue{{Names: []string{"Max"}, Decl: "const Max = 10", Doc: "Max is the largest size\n"}}, p.Consts)
+	if assert.Equal(t, 1, len(p.Types)) {
+		typ := p.Types[0]
+		assert.Equal(t, "type Size int", typ.Decl)
+		assert.Equal(t, "func New(n int) Size", typ.Funcs[0].Decl)
+		assert.Equal(t, []pkgdocExample{{Doc: "", Code: "// double it\nfmt.Println(doc.New(1).Double())", Output: "2\n"}}, typ.Methods[0].Examples)
+	}
+}
+
+func TestPkgdocText(t *testing.T) {
+	p, err := pkgdocBuild(buildContext(fixtureEnv()), fixtureFile("ex/doc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the package is found by its directory, in the GOPATH
+	assert.Equal(t, `PACKAGE DOCUMENTATION
+
+package doc
+    import "ex/doc"
+
+    Package doc is documented for the pkgdoc tests.
+
+CONSTANTS
+
+const Max = 10
+    Max is the largest size
+
+TYPES
+
+type Size int
+    Size is a size
+
+func New(n int) Size
+    New returns n as a Size
+
+func (s Size) Double() Size
+    Double returns twice s
+
+    Example:
+    	// double it
+    	fmt.Println(doc.New(1).Double())
+    Output:
+    	2
+`, pkgdocText(p, 80))
+}
+
+func TestPkgdocSearch(t *testing.T) {
+	env := fixtureEnv()
+	// keep the standard library out of it
+	env["GOROOT"] = filepath.Join(env["GOPATH"], "goroot")
+
+	// the synopsis is that of the package's own files, not the file with the `+build ignore` constraint
+	l := pkgdocSearch(env, "doc", 0, nil)
+	if assert.Equal(t, true, len(l) > 0, "doc") {
+		assert.Equal(t, mPkgdocDoc{Path: "ex/doc", Doc: "Package doc is documented for the pkgdoc tests."}, l[0])
+	}
+
+	paths := []string{}
+	for _, d := range pkgdocSearch(env, "exa", 0, nil) {
+		paths = append(paths, d.Path)
+	}
+	assert.Equal(t, []string{"ex/a", "ex/shapes"}, paths)
+}
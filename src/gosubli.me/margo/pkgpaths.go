@@ -1,8 +1,6 @@
 package main
 
 import (
-	"go/parser"
-	"go/token"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -39,88 +37,31 @@ func ls(fn string) ([]string, bool) {
 	return names, (err == nil || len(names) > 0)
 }
 
-func walk(root string, ch chan string, dir string) {
-	names, ok := ls(dir)
-	if !ok {
-		return
-	}
-
-	for _, nm := range names {
-		if ignoreNm(nm) {
-			continue
-		}
-
-		fn := filepath.Join(dir, nm)
-		isFx, isGo := fx(nm)
-		if isGo {
-			ch <- fn
-		} else if !isFx {
-			walk(root, ch, fn)
-		}
-	}
-}
-
-func pkgPaths(srcDir string, exclude []string) map[string]string {
+func pkgPaths(env map[string]string, srcDir string, exclude []string, sig *cancelSignal) map[string]string {
 	paths := map[string]string{}
-	done := make(chan struct{})
-	ch := make(chan string, 100)
-	fset := token.NewFileSet()
-	seen := map[string]void{}
 	excluded := map[string]void{}
 
 	for _, s := range exclude {
 		excluded[s] = void{}
 	}
 
-	proc := func(fn string) {
-		dir := filepath.Dir(fn)
+	pkgIdx.walk(env, srcDir, true, sig, func(dir string, d *idxDir) {
 		p, err := filepath.Rel(srcDir, dir)
 		if err != nil || strings.HasPrefix(p, ".") {
 			return
 		}
-		p = filepath.ToSlash(p)
-
-		if _, ok := paths[p]; ok {
-			return
-		}
 
-		if _, ok := seen[p]; ok {
+		name := d.pkgName()
+		if name == "" {
 			return
 		}
 
-		af, _ := parser.ParseFile(fset, fn, nil, parser.ImportsOnly|parser.ParseComments)
-		if af == nil || af.Name == nil {
-			return
-		}
-
-		name := af.Name.String()
-
-		for _, cg := range af.Comments {
-			for _, c := range cg.List {
-				if buildIgnore.MatchString(c.Text) {
-					return
-				}
-			}
-		}
-
 		if _, skip := excluded[name]; skip {
-			seen[p] = void{}
 			return
 		}
 
-		paths[p] = name
-	}
-
-	go func() {
-		defer close(done)
-		for fn := range ch {
-			proc(fn)
-		}
-	}()
-
-	walk(srcDir, ch, srcDir)
-	close(ch)
-	<-done
+		paths[filepath.ToSlash(p)] = name
+	})
 
 	return paths
 }
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// remoteMockURL is the base URL of the built-in stand-in for the playground and godoc.org.
+	// requests to it are handled in-process, without touching the network.
+	// it's meant for tests: the URLs of its shares can only be opened by the clients of remoteConfig, not by a browser
+	remoteMockURL = "mock://margo"
+
+	// remoteTimeoutDefault is the default timeout of requests to remote services, in milliseconds
+	remoteTimeoutDefault = 30 * 1000
+)
+
+var (
+	// the defaults of the remote services, they're set by the command line flags
+	shareRemote  = remoteConfig{URL: "http://play.golang.org", Timeout: remoteTimeoutDefault}
+	pkgdocRemote = remoteConfig{URL: "http://godoc.org", Timeout: remoteTimeoutDefault}
+
+	// remoteTransports are shared by the requests with the same config so their connections are re-used
+	remoteTransports = struct {
+		sync.Mutex
+		m map[remoteConfig]*http.Transport
+	}{m: map[remoteConfig]*http.Transport{}}
+
+	mockShares = struct {
+		sync.Mutex
+		m map[string][]byte
+	}{m: map[string][]byte{}}
+)
+
+// remoteConfig configures how a remote service is reached, it's embedded in the methods that use one
+type remoteConfig struct {
+	// URL is the base URL of the service, e.g. that of a private mirror, or remoteMockURL
+	URL string
+	// Timeout is the timeout of each request in milliseconds, there's no timeout if it's zero
+	Timeout int
+	// Proxy is the URL of the HTTP proxy. if it's empty, the proxy is taken from the environment (HTTP_PROXY, etc.)
+	Proxy string
+	// Insecure disables the verification of the server's TLS certificate
+	Insecure bool
+	// CAFile is a PEM file of the certificates that are trusted in addition to the system's
+	CAFile string
+}
+
+func (r remoteConfig) baseURL() string {
+	return strings.TrimRight(r.URL, "/")
+}
+
+// client returns an http client configured according to r
+func (r remoteConfig) client(env map[string]string) (*http.Client, error) {
+	c := &http.Client{
+		Timeout: time.Duration(r.Timeout) * time.Millisecond,
+	}
+	if r.baseURL() == remoteMockURL {
+		c.Transport = mockTransport{h: newRemoteMock(env)}
+		return c, nil
+	}
+
+	t, err := r.transport()
+	if err != nil {
+		return nil, err
+	}
+	c.Transport = t
+	return c, nil
+}
+
+// transport returns the transport shared by the configs that only differ from r by their URL or Timeout
+func (r remoteConfig) transport() (*http.Transport, error) {
+	k := r
+	k.URL = ""
+	k.Timeout = 0
+
+	remoteTransports.Lock()
+	defer remoteTransports.Unlock()
+
+	if t := remoteTransports.m[k]; t != nil {
+		return t, nil
+	}
+
+	t := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: r.Insecure},
+	}
+	if r.Proxy != "" {
+		u, err := url.Parse(r.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy `%s`: %s", r.Proxy, err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if r.CAFile != "" {
+		pem, err := ioutil.ReadFile(r.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in `%s`", r.CAFile)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	remoteTransports.m[k] = t
+	return t, nil
+}
+
+// do sends req with a client configured according to r. the request is aborted if sig is cancelled
+func (r remoteConfig) do(env map[string]string, sig *cancelSignal, req *http.Request) (*http.Response, error) {
+	c, err := r.client(env)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req.WithContext(sig.context()))
+	if err != nil && sig.cancelled() {
+		return nil, cancelledErr
+	}
+	return resp, err
+}
+
+// newRemoteMock returns a handler that serves the parts of the playground and godoc.org protocols that are used by share and pkgdoc.
+// shared snippets are kept in memory and the documentation is built from the local packages in env
+func newRemoteMock(env map[string]string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/share", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		src, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// like the playground, the id is derived from the content
+		sum := sha1.Sum(src)
+		id := base64.URLEncoding.EncodeToString(sum[:])[:10]
+		mockShares.Lock()
+		mockShares.m[id] = src
+		mockShares.Unlock()
+		fmt.Fprint(w, id)
+	})
+	mux.HandleFunc("/p/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/p/"), ".go")
+		mockShares.Lock()
+		src, ok := mockShares.m[id]
+		mockShares.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(src)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+			for _, d := range pkgdocSearch(env, q, 50, nil) {
+				fmt.Fprintf(w, "%s %s\n", d.Path, d.Doc)
+			}
+			return
+		}
+
+		p, err := pkgdocBuild(buildContext(env), strings.Trim(r.URL.Path, "/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, pkgdocText(p, 80))
+	})
+	return mux
+}
+
+// mockTransport serves the requests with the handler h instead of sending them
+type mockTransport struct {
+	h http.Handler
+}
+
+func (t mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := &mockResponse{header: http.Header{}}
+	t.h.ServeHTTP(w, req)
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.code, http.StatusText(w.code)),
+		StatusCode:    w.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          ioutil.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// mockResponse is the http.ResponseWriter used by mockTransport
+type mockResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *mockResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *mockResponse) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.body.Write(p)
+}
+
+func (w *mockResponse) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteMockShare(t *testing.T) {
+	src := "package main\n\nfunc main() {}\n"
+	res, err := (&mShare{Src: src, remoteConfig: remoteConfig{URL: remoteMockURL}}).Call()
+	assert.Equal(t, "", err)
+
+	u, _ := res.(M)["url"].(string)
+	assert.Regexp(t, "^"+remoteMockURL+"/p/", u)
+
+	c, _ := remoteConfig{URL: remoteMockURL}.client(nil)
+	resp, e := c.Get(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer resp.Body.Close()
+	s, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, src, string(s))
+
+	resp, e = c.Get(remoteMockURL + "/p/" + strings.Repeat("x", 10))
+	if e != nil {
+		t.Fatal(e)
+	}
+	resp.Body.Close()
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestRemoteMockPkgdoc(t *testing.T) {
+	env := fixtureEnv()
+	// keep the standard library out of it
+	env["GOROOT"] = filepath.Join(env["GOPATH"], "goroot")
+	mock := remoteConfig{URL: remoteMockURL}
+
+	// the documentation is the text of the local documentation
+	res, err := (&mPkgdoc{Path: "ex/doc", Env: env, remoteConfig: mock}).Call()
+	assert.Equal(t, "", err)
+	p, _ := pkgdocBuild(buildContext(env), "ex/doc")
+	assert.Equal(t, mPkgdocDoc{Path: "ex/doc", Doc: pkgdocText(p, 80)}, res.(M)["doc"])
+
+	// the search results are those of the local search
+	res, err = (&mPkgdoc{Q: "doc", Env: env, remoteConfig: mock}).Call()
+	assert.Equal(t, "", err)
+	results := res.(M)["results"].([]mPkgdocDoc)
+	if assert.Equal(t, true, len(results) > 0, "search") {
+		assert.Equal(t, mPkgdocDoc{Path: "ex/doc", Doc: "Package doc is documented for the pkgdoc tests."}, results[0])
+	}
+}
+
+func TestRemoteTransport(t *testing.T) {
+	a, _ := remoteConfig{URL: "http://a.example", Timeout: 1}.transport()
+	b, _ := remoteConfig{URL: "http://b.example", Timeout: 2}.transport()
+	c, _ := remoteConfig{URL: "http://a.example", Insecure: true}.transport()
+	assert.Equal(t, true, a == b, "the URL and Timeout don't change the transport")
+	assert.Equal(t, true, a != c, "Insecure changes the transport")
+
+	_, err := remoteConfig{Proxy: "%"}.transport()
+	assert.Regexp(t, "invalid proxy", errStr(err))
+}
+
+func TestRemoteCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	sig := newCancelSignal()
+	sig.cancel()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	_, err := remoteConfig{URL: srv.URL}.do(nil, sig, req)
+	assert.Equal(t, cancelledErr, err)
+}
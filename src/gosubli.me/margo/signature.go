@@ -0,0 +1,315 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"gosubli.me/something-borrowed/types"
+)
+
+// SignatureParam is a parameter or result of a Signature, Name is empty if it's unnamed
+type SignatureParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Signature describes the function called at the cursor
+type Signature struct {
+	Name    string           `json:"name"`
+	Params  []SignatureParam `json:"params"`
+	Results []SignatureParam `json:"results"`
+	// Variadic is true if the last parameter is variadic, its Type is then written as `...T`
+	Variadic bool `json:"variadic"`
+	// Active is the index in Params of the parameter for the argument at the cursor,
+	// the variadic parameter is active for all the remaining arguments.
+	// it's -1 if the cursor isn't in the argument list or there are more arguments than parameters
+	Active int    `json:"active"`
+	Doc    string `json:"doc"`
+}
+
+// Type returns the signature as a function type, the way gocode displays it
+func (s *Signature) Type() string {
+	list := func(l []SignatureParam) string {
+		a := make([]string, len(l))
+		for i, p := range l {
+			a[i] = strings.TrimSpace(p.Name + " " + p.Type)
+		}
+		return strings.Join(a, ", ")
+	}
+
+	t := "func(" + list(s.Params) + ")"
+	switch {
+	case len(s.Results) == 1 && s.Results[0].Name == "":
+		t += " " + s.Results[0].Type
+	case len(s.Results) != 0:
+		t += " (" + list(s.Results) + ")"
+	}
+	return t
+}
+
+// setActive sets Active to the parameter that takes the argument at index arg
+func (s *Signature) setActive(arg int) {
+	n := len(s.Params)
+	switch {
+	case arg < 0:
+		s.Active = -1
+	case s.Variadic && arg >= n-1:
+		s.Active = n - 1
+	case arg >= n:
+		s.Active = -1
+	default:
+		s.Active = arg
+	}
+}
+
+// typesSignature type-checks the package containing fn and returns the signature of the call at the byte offset pos.
+// unlike completion, the callee may be any expression of function type, e.g. a method value or the result of another call
+func (g *mGocode) typesSignature(src []byte, fn string, pos int) (sig *Signature) {
+	defer func() {
+		if e := recover(); e != nil {
+			sig = nil
+		}
+	}()
+
+	tf, ok := typesCheck(g.Env, g.sig, src, fn, pos)
+	if !ok {
+		return nil
+	}
+
+	call := enclosingCall(tf.af, tf.pos)
+	if call == nil {
+		return nil
+	}
+	file := tf.w.fset.File(tf.af.Pos())
+	arg := argIndex(src, file.Offset(call.Lparen), pos)
+
+	// the checker only knows the signature of a builtin for the arguments it's called with
+	if b, ok := calleeObject(tf.info, call.Fun).(*types.Builtin); ok {
+		if s := builtinInfoMap[b.Name()]; s != "" {
+			sig = astSignature(b.Name(), "func"+strings.TrimPrefix(s, "func "+b.Name()))
+			sig.setActive(arg)
+		}
+		return sig
+	}
+
+	// conversions look like calls, but there's nothing to help with
+	tv, ok := tf.info.Types[call.Fun]
+	if !ok || tv.IsType() || tv.Type == nil {
+		return nil
+	}
+	st, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		return nil
+	}
+
+	typeString := func(t types.Type) string {
+		return simpleType(types.TypeString(tf.pkg, t))
+	}
+	tuple := func(t *types.Tuple) []SignatureParam {
+		l := make([]SignatureParam, t.Len())
+		for i := range l {
+			v := t.At(i)
+			l[i] = SignatureParam{Name: v.Name(), Type: typeString(v.Type())}
+		}
+		return l
+	}
+
+	sig = &Signature{
+		Name:     calleeName(call.Fun),
+		Params:   tuple(st.Params()),
+		Results:  tuple(st.Results()),
+		Variadic: st.Variadic(),
+	}
+	if n := len(sig.Params); sig.Variadic && n > 0 {
+		if s, ok := st.Params().At(n - 1).Type().(*types.Slice); ok {
+			sig.Params[n-1].Type = "..." + typeString(s.Elem())
+		}
+	}
+
+	sig.setActive(arg)
+
+	if obj := calleeObject(tf.info, call.Fun); obj != nil && obj.Pos().IsValid() {
+		p := tf.w.fset.Position(obj.Pos())
+		var declSrc interface{}
+		if p.Filename == file.Name() {
+			declSrc = src
+		}
+		sig.Doc = declDoc(p.Filename, declSrc, p.Offset)
+	}
+	return sig
+}
+
+// astSignature parses the function type typ, as returned by gocode, into a Signature
+func astSignature(name, typ string) *Signature {
+	x, _ := parser.ParseExpr(typ)
+	ft, ok := x.(*ast.FuncType)
+	if !ok {
+		return nil
+	}
+
+	fields := func(fl *ast.FieldList) []SignatureParam {
+		l := []SignatureParam{}
+		if fl == nil {
+			return l
+		}
+		for _, f := range fl.List {
+			t := types.ExprString(f.Type)
+			if len(f.Names) == 0 {
+				l = append(l, SignatureParam{Type: t})
+			}
+			for _, id := range f.Names {
+				l = append(l, SignatureParam{Name: id.Name, Type: t})
+			}
+		}
+		return l
+	}
+
+	sig := &Signature{
+		Name:    name,
+		Params:  fields(ft.Params),
+		Results: fields(ft.Results),
+	}
+	if n := len(sig.Params); n > 0 {
+		sig.Variadic = strings.HasPrefix(sig.Params[n-1].Type, "...")
+	}
+	return sig
+}
+
+// enclosingCall returns the innermost call whose argument list contains pos or,
+// if pos isn't in an argument list, e.g. it's on the function's name, the innermost call that contains pos
+func enclosingCall(af *ast.File, pos token.Pos) (call *ast.CallExpr) {
+	var outer *ast.CallExpr
+	ast.Inspect(af, func(node ast.Node) bool {
+		if node == nil || pos < node.Pos() || pos > node.End() {
+			return false
+		}
+		if x, ok := node.(*ast.CallExpr); ok {
+			if pos > x.Lparen && pos <= x.Rparen {
+				call = x
+			} else {
+				outer = x
+			}
+		}
+		return true
+	})
+	if call == nil {
+		call = outer
+	}
+	return call
+}
+
+// argIndex returns the index of the argument at the byte offset pos by counting the commas between the call's `(`, at lparen, and pos.
+// it's -1 if pos isn't in the argument list
+func argIndex(src []byte, lparen, pos int) int {
+	if lparen < 0 || pos <= lparen || pos > len(src) {
+		return -1
+	}
+
+	args := src[lparen+1 : pos]
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile("", -1, len(args)), args, nil, 0)
+	depth, n := 0, 0
+	for {
+		_, tok, _ := s.Scan()
+		switch tok {
+		case token.EOF:
+			return n
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if depth == 0 {
+				return -1
+			}
+			depth--
+		case token.COMMA:
+			if depth == 0 {
+				n++
+			}
+		}
+	}
+}
+
+// calleeName returns the name the function is called by, or the expression if it's not called by name
+func calleeName(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return calleeName(x.X)
+	case *ast.Ident:
+		return x.Name
+	case *ast.SelectorExpr:
+		return x.Sel.Name
+	}
+	return types.ExprString(x)
+}
+
+// calleeObject returns the function or variable that's called, if it's called by name
+func calleeObject(info *types.Info, x ast.Expr) types.Object {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return calleeObject(info, x.X)
+	case *ast.Ident:
+		return info.Uses[x]
+	case *ast.SelectorExpr:
+		return info.Uses[x.Sel]
+	}
+	return nil
+}
+
+// declDoc returns the doc comment of the function, method, field, type, constant or variable declared at the byte offset in the file fn
+func declDoc(fn string, src interface{}, offset int) string {
+	if filepath.Ext(fn) != ".go" {
+		return ""
+	}
+
+	fset := token.NewFileSet()
+	af, _ := parser.ParseFile(fset, fn, src, parser.ParseComments)
+	if af == nil {
+		return ""
+	}
+
+	at := func(l ...*ast.Ident) bool {
+		for _, id := range l {
+			if id != nil && fset.Position(id.Pos()).Offset == offset {
+				return true
+			}
+		}
+		return false
+	}
+	doc := ""
+	found := false
+	ast.Inspect(af, func(node ast.Node) bool {
+		switch x := node.(type) {
+		case *ast.FuncDecl:
+			if at(x.Name) {
+				doc, found = x.Doc.Text(), true
+			}
+		case *ast.Field:
+			if at(x.Names...) {
+				doc, found = x.Doc.Text(), true
+			}
+		case *ast.GenDecl:
+			for _, spec := range x.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					if at(spec.Names...) {
+						doc, found = spec.Doc.Text(), true
+					}
+				case *ast.TypeSpec:
+					if at(spec.Name) {
+						doc, found = spec.Doc.Text(), true
+					}
+				}
+				// a single declaration is usually documented outside of the parentheses
+				if found && doc == "" {
+					doc = x.Doc.Text()
+				}
+			}
+		}
+		return !found
+	})
+	return strings.TrimSpace(doc)
+}
@@ -0,0 +1,10 @@
+package d
+
+import "ex/a"
+
+type D struct{}
+
+// Val returns the value of t
+func (D) Val(t *a.T) int {
+	return t.Val
+}
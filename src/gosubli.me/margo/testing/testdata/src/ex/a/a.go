@@ -0,0 +1,27 @@
+package a
+
+// Namer is implemented by *T
+type Namer interface {
+	Name() string
+}
+
+// T is a value with a size
+type T struct {
+	Val  int
+	Size int
+}
+
+// Name returns the name of t
+func (t *T) Name() string {
+	return "t"
+}
+
+// New returns a T for v
+func New(v int) *T {
+	x := v
+	if x > 0 {
+		y := 1
+		x += y
+	}
+	return &T{Val: x}
+}
@@ -0,0 +1,9 @@
+package b
+
+import "ex/a"
+
+var N a.Namer = a.New(1)
+
+func Val(t *a.T) int {
+	return t.Val + t.Size
+}
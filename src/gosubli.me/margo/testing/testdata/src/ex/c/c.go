@@ -0,0 +1,8 @@
+package c
+
+// Named implements a.Namer without importing it
+type Named string
+
+func (n Named) Name() string {
+	return string(n)
+}
@@ -0,0 +1,16 @@
+package c
+
+type E struct{}
+
+func (E) Error() string {
+	return "e"
+}
+
+// Describe returns the message of err
+func Describe(err error) string {
+	return err.Error()
+}
+
+func describeE() string {
+	return Describe(E{})
+}
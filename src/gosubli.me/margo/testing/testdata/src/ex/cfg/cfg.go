@@ -0,0 +1,4 @@
+package cfg
+
+// Load shares its name with the method of ex/e's package-level var cfg
+func Load() {}
@@ -0,0 +1,5 @@
+//go:build ignore
+// +build ignore
+
+// Package doc is generated by this file, which isn't a part of the package.
+package doc
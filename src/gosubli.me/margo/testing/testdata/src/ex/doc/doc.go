@@ -0,0 +1,18 @@
+// Package doc is documented for the pkgdoc tests.
+package doc
+
+// Max is the largest size
+const Max = 10
+
+// Size is a size
+type Size int
+
+// New returns n as a Size
+func New(n int) Size {
+	return Size(n)
+}
+
+// Double returns twice s
+func (s Size) Double() Size {
+	return 2 * s
+}
@@ -0,0 +1,12 @@
+package doc_test
+
+import (
+	"ex/doc"
+	"fmt"
+)
+
+func ExampleSize_Double() {
+	// double it
+	fmt.Println(doc.New(1).Double())
+	// Output: 2
+}
@@ -0,0 +1,7 @@
+package e
+
+type config struct{}
+
+func (config) Load() {}
+
+var cfg config
@@ -0,0 +1,19 @@
+// Package shapes declares types whose members refer to the other types of the package
+package shapes
+
+type Factor float64
+
+type Point struct {
+	X, Y float64
+}
+
+type Box struct {
+	Min, Max Point
+}
+
+type Shape interface {
+	Area() float64
+	Scale(f Factor) Shape
+}
+
+func Resize(Shape, Factor) {}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gosubli.me/something-borrowed/gocode"
+	"gosubli.me/something-borrowed/types"
+)
+
+// typesCandClasses orders the candidates the same way gocode does
+var typesCandClasses = map[string]int{
+	"const":   0,
+	"func":    1,
+	"package": 2,
+	"type":    3,
+	"var":     4,
+}
+
+type typesCands []gocode.MargoCandidate
+
+func (l typesCands) Len() int {
+	return len(l)
+}
+
+func (l typesCands) Less(i, j int) bool {
+	a, b := l[i], l[j]
+	if a.Class != b.Class {
+		return typesCandClasses[a.Class] < typesCandClasses[b.Class]
+	}
+	return a.Name < b.Name
+}
+
+func (l typesCands) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// typesCandsByScore orders the candidates by their score first, like gocode does when fuzzy matching
+type typesCandsByScore struct {
+	typesCands
+}
+
+func (l typesCandsByScore) Less(i, j int) bool {
+	if a, b := l.typesCands[i], l.typesCands[j]; a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return l.typesCands.Less(i, j)
+}
+
+// typesCompleter proposes the objects that are in scope at the cursor or, after a `.`,
+// the members of the package, the fields and methods of the value or the methods of the type on its left
+type typesCompleter struct {
+	w        *PkgWalker
+	pkg      *types.Package
+	info     *types.Info
+	builtins bool
+	// fuzzy matches the names to the partial identifier with gocode.FuzzyMatch instead of by prefix
+	fuzzy bool
+
+	partial string
+	names   map[string]bool
+	cands   typesCands
+}
+
+// typesComplete type-checks the package containing fn and returns the completions at the byte offset pos.
+// ok is false if the package could not be checked or the context at the cursor could not be determined,
+// in which case the caller should fall back to gocode
+func (g *mGocode) typesComplete(src []byte, fn string, pos int) (cands []gocode.MargoCandidate, ok bool) {
+	defer func() {
+		// the checker isn't always happy with the incomplete code we feed it
+		if e := recover(); e != nil {
+			cands, ok = nil, false
+		}
+	}()
+
+	if pos < 0 || pos > len(src) {
+		return nil, false
+	}
+
+	start := pos
+	for start > 0 {
+		r, n := utf8.DecodeLastRune(src[:start])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		start -= n
+	}
+	partial := string(src[start:pos])
+
+	dot := start
+	for dot > 0 && (src[dot-1] == ' ' || src[dot-1] == '\t' || src[dot-1] == '\n' || src[dot-1] == '\r') {
+		dot--
+	}
+	isSel := dot > 0 && src[dot-1] == '.'
+
+	// `x.` isn't valid syntax, so we complete the selector with a placeholder to keep the parser on track
+	s := make([]byte, 0, len(src)+1)
+	s = append(s, src[:pos]...)
+	if isSel && partial == "" {
+		s = append(s, '_')
+	}
+	s = append(s, src[pos:]...)
+
+	tf, ok := typesCheck(g.Env, g.sig, s, fn, start)
+	if !ok {
+		return nil, false
+	}
+
+	c := &typesCompleter{
+		w:        tf.w,
+		pkg:      tf.pkg,
+		info:     tf.info,
+		builtins: g.Builtins,
+		fuzzy:    g.Fuzzy,
+		partial:  partial,
+	}
+	if isSel {
+		ok = c.members(tf.af, tf.pos)
+	} else {
+		ok = c.scope(tf.af, tf.pos)
+	}
+	if !ok {
+		return nil, false
+	}
+	if c.fuzzy {
+		sort.Sort(typesCandsByScore{c.cands})
+	} else {
+		sort.Sort(c.cands)
+	}
+	return c.cands, true
+}
+
+// typesFile is the file being edited, in its type-checked package
+type typesFile struct {
+	w    *PkgWalker
+	pkg  *types.Package
+	info *types.Info
+	af   *ast.File
+	// pos is the position of the cursor in af
+	pos token.Pos
+}
+
+// typesCheck type-checks the package containing fn, with src as the content of fn, and locates the byte offset pos in it.
+// ok is false if the package could not be imported or fn could not be found in it
+func typesCheck(env map[string]string, sig *cancelSignal, src []byte, fn string, pos int) (tf typesFile, ok bool) {
+	w := NewPkgWalker(buildContext(env), false, false, false)
+	w.sig = sig
+	cursor := &FileCursor{
+		src:       src,
+		cursorPos: pos,
+		fileName:  filepath.Base(fn),
+		fileDir:   filepath.Dir(fn),
+	}
+	conf := &PkgConfig{
+		AllowBinary:   true,
+		WithTestFiles: true,
+		Lenient:       true,
+		Cursor:        cursor,
+		Info: &types.Info{
+			Types:      map[ast.Expr]types.TypeAndValue{},
+			Defs:       map[*ast.Ident]types.Object{},
+			Uses:       map[*ast.Ident]types.Object{},
+			Scopes:     map[ast.Node]*types.Scope{},
+			Selections: map[*ast.SelectorExpr]*types.Selection{},
+		},
+	}
+	// type errors are expected in code that is being edited, so only a failure to import the package is fatal
+	pkg, _ := w.Import("", cursor.fileDir, conf)
+	if pkg == nil || !cursor.pos.IsValid() {
+		return tf, false
+	}
+
+	var af *ast.File
+	for node := range conf.Info.Scopes {
+		if f, ok := node.(*ast.File); ok {
+			if file := w.fset.File(f.Pos()); file != nil && file.Name() == filepath.Join(cursor.fileDir, cursor.fileName) {
+				af = f
+			}
+		}
+	}
+	if af == nil {
+		return tf, false
+	}
+
+	// the cursor file may belong to the external test package
+	if xpkg := w.imported[pkg.Path()+"_test"]; xpkg != nil && af.Name.Name == xpkg.Name() {
+		pkg = xpkg
+	}
+
+	return typesFile{w: w, pkg: pkg, info: conf.Info, af: af, pos: cursor.pos}, true
+}
+
+// members proposes the members of the expression on the left of the selector at pos
+func (c *typesCompleter) members(af *ast.File, pos token.Pos) bool {
+	var sel *ast.SelectorExpr
+	ast.Inspect(af, func(node ast.Node) bool {
+		if node == nil || pos < node.Pos() || pos > node.End() {
+			return false
+		}
+		if x, ok := node.(*ast.SelectorExpr); ok && x.Sel.Pos() == pos {
+			sel = x
+		}
+		return sel == nil
+	})
+	if sel == nil {
+		return false
+	}
+
+	if id, ok := sel.X.(*ast.Ident); ok {
+		if pn, ok := c.info.Uses[id].(*types.PkgName); ok {
+			scope := pn.Imported().Scope()
+			c.each(func(add func(types.Object)) {
+				for _, name := range scope.Names() {
+					add(scope.Lookup(name))
+				}
+			})
+			return true
+		}
+	}
+
+	tv, ok := c.info.Types[sel.X]
+	if !ok || tv.Type == nil || tv.Type == types.Typ[types.Invalid] {
+		return false
+	}
+
+	T := tv.Type
+	c.each(func(add func(types.Object)) {
+		if !tv.IsType() {
+			c.fields(T, add)
+		}
+
+		// the methods of *T are proposed for values of type T too, since they're usually addressable
+		msets := []*types.MethodSet{types.NewMethodSet(T)}
+		switch T.Underlying().(type) {
+		case *types.Pointer, *types.Interface:
+		default:
+			msets = append(msets, types.NewMethodSet(types.NewPointer(T)))
+		}
+		for _, mset := range msets {
+			for i := 0; i < mset.Len(); i++ {
+				add(mset.At(i).Obj())
+			}
+		}
+	})
+	return true
+}
+
+// fields adds the fields of the struct T or *T, including promoted fields, shallowest first
+func (c *typesCompleter) fields(T types.Type, add func(types.Object)) {
+	seen := map[*types.Struct]bool{}
+	level := []types.Type{T}
+	for len(level) > 0 {
+		next := []types.Type{}
+		for _, t := range level {
+			if p, ok := t.Underlying().(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			st, ok := t.Underlying().(*types.Struct)
+			if !ok || seen[st] {
+				continue
+			}
+			seen[st] = true
+
+			for i := 0; i < st.NumFields(); i++ {
+				f := st.Field(i)
+				add(f)
+				if f.Anonymous() {
+					next = append(next, f.Type())
+				}
+			}
+		}
+		level = next
+	}
+}
+
+// scope proposes the objects visible at pos
+func (c *typesCompleter) scope(af *ast.File, pos token.Pos) bool {
+	var scope *types.Scope
+	ast.Inspect(af, func(node ast.Node) bool {
+		if node == nil || pos < node.Pos() || pos > node.End() {
+			return false
+		}
+		// function scopes are recorded against the signature, but also cover the body
+		switch x := node.(type) {
+		case *ast.FuncDecl:
+			node = x.Type
+		case *ast.FuncLit:
+			node = x.Type
+		}
+		if s := c.info.Scopes[node]; s != nil {
+			scope = s
+		}
+		return true
+	})
+	if scope == nil {
+		return false
+	}
+
+	c.each(func(add func(types.Object)) {
+		for s := scope; s != nil; s = s.Parent() {
+			if s == types.Universe && !c.builtins {
+				break
+			}
+
+			local := s != types.Universe && s != c.pkg.Scope() && s != c.info.Scopes[af]
+			for _, name := range s.Names() {
+				obj := s.Lookup(name)
+				// local declarations aren't visible before they're declared
+				if local && obj.Pos() > pos {
+					continue
+				}
+				add(obj)
+			}
+		}
+	})
+	return true
+}
+
+// each calls f to collect the objects, only adding those whose name starts with, or fuzzy matches, the partial identifier at the cursor.
+// like gocode, if no names match, the match is retried ignoring case
+func (c *typesCompleter) each(f func(add func(types.Object))) {
+	for _, ignoreCase := range []bool{false, true} {
+		c.names = map[string]bool{}
+		f(func(obj types.Object) {
+			c.add(obj, ignoreCase)
+		})
+		if len(c.cands) != 0 || c.partial == "" {
+			break
+		}
+	}
+}
+
+func (c *typesCompleter) add(obj types.Object, ignoreCase bool) {
+	if obj == nil {
+		return
+	}
+
+	name := obj.Name()
+	if name == "_" || c.names[name] {
+		return
+	}
+	if obj.Pkg() != nil && obj.Pkg() != c.pkg && !obj.Exported() {
+		return
+	}
+	if c.fuzzy {
+		if _, ok := gocode.FuzzyMatch(c.partial, name); !ok {
+			return
+		}
+	} else if ignoreCase {
+		if !strings.HasPrefix(strings.ToLower(name), strings.ToLower(c.partial)) {
+			return
+		}
+	} else if !strings.HasPrefix(name, c.partial) {
+		return
+	}
+
+	cand := gocode.MargoCandidate{Name: name}
+	switch o := obj.(type) {
+	case *types.Func:
+		cand.Class = "func"
+		cand.Type = c.typeString(o.Type())
+	case *types.Builtin:
+		cand.Class = "func"
+		cand.Type = strings.TrimPrefix(builtinInfoMap[name], "func "+name)
+		if cand.Type != "" {
+			cand.Type = "func" + cand.Type
+		}
+	case *types.Var:
+		cand.Class = "var"
+		cand.Type = c.typeString(o.Type())
+	case *types.Const:
+		cand.Class = "const"
+		cand.Type = c.typeString(o.Type())
+	case *types.TypeName:
+		cand.Class = "type"
+		switch u := o.Type().Underlying().(type) {
+		case *types.Struct:
+			cand.Type = "struct"
+		case *types.Interface:
+			cand.Type = "interface"
+		default:
+			cand.Type = c.typeString(u)
+		}
+	case *types.PkgName:
+		cand.Class = "package"
+	case *types.Nil:
+		cand.Class = "var"
+	default:
+		return
+	}
+
+	// the candidates are ranked like gocode's, locals are those declared below the file scope
+	scope := obj.Parent()
+	universe := scope == types.Universe
+	local := scope != nil && !universe && scope != c.pkg.Scope() && scope.Parent() != c.pkg.Scope()
+	cand.Score = gocode.Margo.Score(c.partial, name, local, universe)
+
+	c.names[name] = true
+	c.cands = append(c.cands, cand)
+}
+
+// typeString returns the type as gocode displays it, with packages qualified by their name
+func (c *typesCompleter) typeString(t types.Type) string {
+	return simpleType(types.TypeString(c.pkg, t))
+}
@@ -22,9 +22,11 @@ import (
 
 // fields must be exported for RPC
 type candidate struct {
-	Name  string
-	Type  string
-	Class decl_class
+	Name    string
+	Type    string
+	Class   decl_class
+	Snippet string
+	Score   int
 }
 
 type out_buffers struct {
@@ -33,6 +35,18 @@ type out_buffers struct {
 	ctx        *auto_complete_context
 	tmpns      map[string]bool
 	ignorecase bool
+
+	// the candidates are the names in scope rather than the members of a package or type,
+	// so statement snippets can be proposed
+	inscope bool
+
+	// the name the package is imported as, when the candidates are its members.
+	// it qualifies the package's types in the snippets
+	qualifier string
+
+	// see rank.go
+	locals   map[*decl]bool
+	expected string
 }
 
 func new_out_buffers(ctx *auto_complete_context) *out_buffers {
@@ -50,7 +64,15 @@ func (b *out_buffers) Len() int {
 func (b *out_buffers) Less(i, j int) bool {
 	x := b.candidates[i]
 	y := b.candidates[j]
+	// without fuzzy matching, the candidates keep gocode's usual order
+	if g_config.ProposeFuzzy && x.Score != y.Score {
+		return x.Score > y.Score
+	}
 	if x.Class == y.Class {
+		if x.Name == y.Name {
+			// the plain candidate comes before the extra snippet candidates with the same name
+			return x.Snippet == "" && y.Snippet != ""
+		}
 		return x.Name < y.Name
 	}
 	return x.Class < y.Class
@@ -63,7 +85,7 @@ func (b *out_buffers) Swap(i, j int) {
 func (b *out_buffers) append_decl(p, name string, decl *decl, class decl_class) {
 	c1 := !g_config.ProposeBuiltins && decl.scope == g_universe_scope && decl.name != "Error"
 	c2 := class != decl_invalid && decl.class != class
-	c3 := class == decl_invalid && !has_prefix(name, p, b.ignorecase)
+	c3 := class == decl_invalid && !b.matches(name, p)
 	c4 := !decl.matches()
 	c5 := !check_type_expr(decl.typ)
 
@@ -72,12 +94,46 @@ func (b *out_buffers) append_decl(p, name string, decl *decl, class decl_class)
 	}
 
 	decl.pretty_print_type(b.tmpbuf)
-	b.candidates = append(b.candidates, candidate{
+	c := candidate{
 		Name:  name,
 		Type:  b.tmpbuf.String(),
 		Class: decl.class,
-	})
+	}
 	b.tmpbuf.Reset()
+	c.Score = b.score(p, name, decl, c.Type)
+	if !g_config.ProposeSnippets {
+		b.candidates = append(b.candidates, c)
+		return
+	}
+
+	if t, ok := decl.typ.(*ast.FuncType); ok && decl.class == decl_func {
+		c.Snippet = func_snippet(name, t, b.qualifier)
+	}
+	b.candidates = append(b.candidates, c)
+
+	// templates are proposed as extra candidates so the plain name can still be inserted
+	extra := func(typ, snippet string) {
+		if snippet != "" {
+			b.candidates = append(b.candidates, candidate{
+				Name:    name,
+				Type:    typ,
+				Class:   decl.class,
+				Snippet: snippet,
+				Score:   c.Score - 1,
+			})
+		}
+	}
+	switch decl.class {
+	case decl_type:
+		extra("struct literal", struct_snippet(name, decl, b.qualifier))
+		if b.inscope || b.qualifier != "" {
+			extra("method stubs", interface_snippet(decl, b.qualifier))
+		}
+	case decl_var:
+		if b.inscope {
+			extra("for range", range_snippet(name, decl))
+		}
+	}
 }
 
 func (b *out_buffers) append_embedded(p string, decl *decl, class decl_class) {
@@ -138,6 +194,9 @@ type auto_complete_context struct {
 	others  []*decl_file_cache  // other files of the current package
 	pkg     *scope
 
+	// the bonus for recently accepted names, see rank.go
+	recent map[string]int
+
 	pcache    package_cache // packages cache
 	declcache *decl_cache   // top-level declarations cache
 }
@@ -162,7 +221,7 @@ func (c *auto_complete_context) update_caches() {
 		c.pcache.append_packages(ps, other.packages)
 	}
 
-	update_packages(ps)
+	update_packages(ps, c.declcache.env)
 
 	// fix imports for all files
 	fixup_packages(c.current.filescope, c.current.packages, c.pcache)
@@ -255,6 +314,8 @@ func (c *auto_complete_context) apropos(file []byte, filename string, cursor int
 		return nil, 0
 	}
 
+	b.expected = c.deduce_expected_type(file, cursor-len(cc.partial))
+
 	class := decl_invalid
 	switch cc.partial {
 	case "const":
@@ -271,6 +332,8 @@ func (c *auto_complete_context) apropos(file []byte, filename string, cursor int
 
 	if cc.decl == nil {
 		// In case if no declaraion is a subject of completion, propose all:
+		b.inscope = true
+		b.locals = c.local_decls()
 		set := c.make_decl_set(c.current.scope)
 		c.get_candidates_from_set(set, cc.partial, class, b)
 		if cc.partial != "" && len(b.candidates) == 0 {
@@ -279,6 +342,9 @@ func (c *auto_complete_context) apropos(file []byte, filename string, cursor int
 			c.get_candidates_from_set(set, cc.partial, class, b)
 		}
 	} else {
+		if cc.decl.class == decl_package {
+			b.qualifier = qualifier_at(file, cursor-len(cc.partial))
+		}
 		c.get_candidates_from_decl(cc, class, b)
 		if cc.partial != "" && len(b.candidates) == 0 {
 			// as a fallback, try case insensitive approach
@@ -311,7 +377,28 @@ func (c *auto_complete_context) cursor_type_pkg(file []byte, filename string, cu
 	return tmp.String(), pkg
 }
 
-func update_packages(ps map[string]*package_file_cache) {
+// returns the type of the expression expr, as inferred in the scope at the cursor.
+// unlike cursor_type_pkg, the expression needn't be followed by a '.'
+func (c *auto_complete_context) expr_type(file []byte, filename string, cursor int, expr string) string {
+	c.current.cursor = cursor
+	c.current.name = filename
+	c.current.process_data(file)
+	c.update_caches()
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return ""
+	}
+	typ, _, _ := infer_type(e, c.current.scope, -1)
+	if typ == nil {
+		return ""
+	}
+
+	var tmp bytes.Buffer
+	pretty_print_type_expr(&tmp, typ)
+	return tmp.String()
+}
+
+func update_packages(ps map[string]*package_file_cache, env *gocode_env) {
 	// initiate package cache update
 	done := make(chan bool)
 	for _, p := range ps {
@@ -322,7 +409,7 @@ func update_packages(ps map[string]*package_file_cache) {
 					done <- false
 				}
 			}()
-			p.update_cache()
+			p.update_cache(env)
 			done <- true
 		}(p)
 	}
@@ -21,11 +21,15 @@ import (
 type config struct {
 	ProposeBuiltins bool   `json:"propose-builtins"`
 	LibPath         string `json:"lib-path"`
+	ProposeSnippets bool   `json:"propose-snippets"`
+	ProposeFuzzy    bool   `json:"propose-fuzzy"`
 }
 
 var g_config = config{
 	false,
 	"",
+	false,
+	false,
 }
 
 var g_string_to_bool = map[string]bool{
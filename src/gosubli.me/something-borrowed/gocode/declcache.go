@@ -3,6 +3,7 @@ package gocode
 import (
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
 	"os"
@@ -28,6 +29,23 @@ type gocode_env struct {
 	GOOS   string
 }
 
+// Returns the default build context with the paths, OS and architecture of env,
+// so the files of a package are selected the way the configured toolchain would.
+func (env *gocode_env) build_context() *build.Context {
+	ctx := build.Default
+	if env.GOROOT != "" {
+		ctx.GOROOT = env.GOROOT
+	}
+	ctx.GOPATH = env.GOPATH
+	if env.GOOS != "" {
+		ctx.GOOS = env.GOOS
+	}
+	if env.GOARCH != "" {
+		ctx.GOARCH = env.GOARCH
+	}
+	return &ctx
+}
+
 func (env *gocode_env) get() {
 	env.GOPATH = os.Getenv("GOPATH")
 	env.GOROOT = os.Getenv("GOROOT")
@@ -241,7 +259,12 @@ func find_global_file(imp string, env *gocode_env) (string, bool) {
 		}
 	}
 	goroot_pkg := filepath.Join(env.GOROOT, pkgpath)
-	return goroot_pkg, file_exists(goroot_pkg)
+	if file_exists(goroot_pkg) {
+		return goroot_pkg, true
+	}
+
+	// there's no archive, fall back to reading the package's source
+	return find_global_source(imp, env)
 }
 
 func package_name(file *ast.File) string {
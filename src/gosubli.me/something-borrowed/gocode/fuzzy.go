@@ -0,0 +1,55 @@
+package gocode
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// FuzzyMatch reports whether the characters of `query` appear in `s`, in order, ignoring case.
+// The score is higher when the characters match at the start of words (e.g.
+// `srvHndl` matching `ServerHandler`), are consecutive or match exactly, and
+// when fewer characters are left unmatched.
+//
+// It's shared with margo, which matches symbol names and import paths with it.
+func FuzzyMatch(query, s string) (score int, ok bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	qr := []rune(query)
+	qi := 0
+	prev_match := -2
+	prev := rune(0)
+	i := 0
+	for pos, r := range s {
+		if qi < len(qr) && unicode.ToLower(r) == unicode.ToLower(qr[qi]) {
+			score += 1
+			if r == qr[qi] {
+				score += 1
+			}
+			switch {
+			case pos == 0:
+				score += 10
+			case prev == '_' || prev == '.' || (unicode.IsUpper(r) && !unicode.IsUpper(prev)):
+				score += 8
+			}
+			if prev_match == i-1 {
+				score += 4
+			}
+			prev_match = i
+			qi++
+		}
+		prev = r
+		i++
+	}
+
+	if qi < len(qr) {
+		return 0, false
+	}
+
+	n := utf8.RuneCountInString(s)
+	if n == len(qr) {
+		score += 20
+	}
+	return score - (n-len(qr))/2, true
+}
@@ -0,0 +1,28 @@
+package gocode
+
+import (
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	if _, ok := FuzzyMatch("srvHndl", "ServerHandler"); !ok {
+		t.Errorf("srvHndl doesn't match ServerHandler")
+	}
+	if _, ok := FuzzyMatch("hs", "ServerHandler"); ok {
+		t.Errorf("hs matches ServerHandler")
+	}
+
+	exact, _ := FuzzyMatch("handler", "Handler")
+	prefix, _ := FuzzyMatch("handler", "HandlerFunc")
+	inner, _ := FuzzyMatch("handler", "ServerHandler")
+	scattered, _ := FuzzyMatch("handler", "HasNoDefaultLayer")
+	if !(exact > prefix && prefix > inner && inner > scattered) {
+		t.Errorf("want exact(%d) > prefix(%d) > inner(%d) > scattered(%d)", exact, prefix, inner, scattered)
+	}
+
+	sel, _ := FuzzyMatch("util", "io.util")
+	word, _ := FuzzyMatch("util", "ioxutil")
+	if sel <= word {
+		t.Errorf("want the match after a `.` (%d) > the match inside a word (%d)", sel, word)
+	}
+}
@@ -14,6 +14,16 @@ type MargoConfig struct {
 	InstallSuffix string
 	GOROOT        string
 	GOPATHS       []string
+
+	// Snippets adds insertable snippets to the candidates, see MargoCandidate.Snippet
+	Snippets bool
+
+	// Fuzzy matches the candidates to the partial identifier with a fuzzy, camel-case aware match
+	// instead of by prefix
+	Fuzzy bool
+
+	// if Cancel is closed before the completion starts, no candidates are returned
+	Cancel <-chan struct{}
 }
 
 type margoState struct {
@@ -23,12 +33,24 @@ type margoState struct {
 	env       *gocode_env
 	pkgCache  package_cache
 	declCache *decl_cache
+
+	// the names of the most recently accepted candidates, most recent first
+	recent []string
 }
 
 type MargoCandidate struct {
 	Name  string `json:"name"`
 	Type  string `json:"type"`
 	Class string `json:"class"`
+	// Snippet is the text to insert instead of Name, in the Sublime Text snippet format.
+	// funcs have a call snippet with their parameters as placeholders.
+	// extra candidates are added for the struct literal of struct types and, when completing the names in scope,
+	// the method stubs of interface types and the `for range` statement of slice, array, map and channel vars
+	Snippet string `json:"snippet,omitempty"`
+	// Score ranks the candidate's relevance, the candidates are sorted by it when Fuzzy is set.
+	// it favours good matches of the partial identifier, locals over package-level and builtin names,
+	// values of the type expected at the cursor and names that were accepted recently
+	Score int `json:"score"`
 }
 
 func newMargoState() *margoState {
@@ -47,20 +69,77 @@ func (m *margoState) Complete(c MargoConfig, file []byte, filename string, curso
 	m.Lock()
 	defer m.Unlock()
 
+	// requests queue up behind the lock so it's likely the client no longer wants this result
+	select {
+	case <-c.Cancel:
+		return []MargoCandidate{}
+	default:
+	}
+
 	m.updateConfig(c)
+	m.ctx.recent = m.recentBoosts()
 
 	list, _ := m.ctx.apropos(file, filename, cursor)
 	candidates := make([]MargoCandidate, len(list))
 	for i, c := range list {
 		candidates[i] = MargoCandidate{
-			Name:  c.Name,
-			Type:  c.Type,
-			Class: c.Class.String(),
+			Name:    c.Name,
+			Type:    c.Type,
+			Class:   c.Class.String(),
+			Snippet: c.Snippet,
+			Score:   c.Score,
 		}
 	}
 	return candidates
 }
 
+// ExprType returns the type of the expression expr, as inferred in the scope at the cursor, or "" if it can't be inferred.
+// it's used to find the type of callees and receivers that gocode can't complete by name, e.g. the result of a call
+func (m *margoState) ExprType(c MargoConfig, file []byte, filename string, cursor int, expr string) string {
+	m.Lock()
+	defer m.Unlock()
+
+	select {
+	case <-c.Cancel:
+		return ""
+	default:
+	}
+
+	m.updateConfig(c)
+	return m.ctx.expr_type(file, filename, cursor, expr)
+}
+
+// Accepted records that the candidate `name` was inserted by the user, boosting its score in later completions.
+func (m *margoState) Accepted(name string) {
+	m.Lock()
+	defer m.Unlock()
+
+	recent := []string{name}
+	for _, s := range m.recent {
+		if s != name && len(recent) < margo_recent_max {
+			recent = append(recent, s)
+		}
+	}
+	m.recent = recent
+}
+
+// Score returns the score of a candidate found by another engine, ranked like gocode's own candidates.
+// local reports whether it's declared in the function around the cursor and universe whether it's a builtin.
+func (m *margoState) Score(partial, name string, local, universe bool) int {
+	m.Lock()
+	defer m.Unlock()
+
+	return rank(partial, name, local, universe, false, m.recentBoosts()[name])
+}
+
+func (m *margoState) recentBoosts() map[string]int {
+	boosts := make(map[string]int, len(m.recent))
+	for i, name := range m.recent {
+		boosts[name] = rank_recent - i
+	}
+	return boosts
+}
+
 func (m *margoState) updateConfig(c MargoConfig) {
 	pl := []string{}
 	osArch := runtime.GOOS + "_" + runtime.GOARCH
@@ -78,6 +157,17 @@ func (m *margoState) updateConfig(c MargoConfig) {
 		add(p)
 	}
 
+	// the env is used to find the source of packages that have no archive in LibPath
+	m.env.GOROOT = c.GOROOT
+	if m.env.GOROOT == "" {
+		m.env.GOROOT = runtime.GOROOT()
+	}
+	m.env.GOPATH = strings.Join(c.GOPATHS, string(filepath.ListSeparator))
+	m.env.GOOS = runtime.GOOS
+	m.env.GOARCH = runtime.GOARCH
+
 	g_config.ProposeBuiltins = c.Builtins
+	g_config.ProposeSnippets = c.Snippets
+	g_config.ProposeFuzzy = c.Fuzzy
 	g_config.LibPath = strings.Join(pl, string(filepath.ListSeparator))
 }
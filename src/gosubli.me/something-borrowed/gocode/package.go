@@ -70,7 +70,7 @@ func (m *package_file_cache) find_file() string {
 	return m.name
 }
 
-func (m *package_file_cache) update_cache() {
+func (m *package_file_cache) update_cache(env *gocode_env) {
 	if m.mtime == -1 {
 		return
 	}
@@ -79,6 +79,10 @@ func (m *package_file_cache) update_cache() {
 	if err != nil {
 		return
 	}
+	if stat.IsDir() {
+		m.update_source_cache(fname, env)
+		return
+	}
 
 	statmtime := stat.ModTime().UnixNano()
 	if m.mtime != statmtime {
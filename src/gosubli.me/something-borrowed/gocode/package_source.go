@@ -0,0 +1,130 @@
+package gocode
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// package_file_cache from source
+//
+// When a package has no archive (it wasn't installed or failed to install),
+// its declarations are read from the source files in its directory instead.
+// Imports of the source package are only known by name, so types from other
+// packages that are referenced by its declarations can't be followed.
+//-------------------------------------------------------------------------
+
+// Looks for the source directory of the package `imp` in GOPATH, then in GOROOT.
+func find_global_source(imp string, env *gocode_env) (string, bool) {
+	dirs := []string{}
+	if env.GOPATH != "" {
+		for _, p := range filepath.SplitList(env.GOPATH) {
+			dirs = append(dirs, filepath.Join(p, "src", imp))
+		}
+	}
+	if env.GOROOT != "" {
+		dirs = append(dirs,
+			filepath.Join(env.GOROOT, "src", imp),
+			filepath.Join(env.GOROOT, "src", "pkg", imp),
+		)
+	}
+
+	for _, dir := range dirs {
+		if is_dir(dir) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+func is_dir(filename string) bool {
+	fi, err := os.Stat(filename)
+	return err == nil && fi.IsDir()
+}
+
+// Returns the latest modification time of the directory and the go files in it.
+// The directory's own mtime only changes when files are added or removed.
+func source_mtime(dir string) int64 {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return 0
+	}
+	mtime := fi.ModTime().UnixNano()
+
+	files, _ := ioutil.ReadDir(dir)
+	for _, fi := range files {
+		if strings.HasSuffix(fi.Name(), ".go") {
+			if t := fi.ModTime().UnixNano(); t > mtime {
+				mtime = t
+			}
+		}
+	}
+	return mtime
+}
+
+func (m *package_file_cache) update_source_cache(dir string, env *gocode_env) {
+	mtime := source_mtime(dir)
+	if m.mtime != mtime {
+		m.mtime = mtime
+		m.process_package_source(dir, env)
+	}
+}
+
+func (m *package_file_cache) process_package_source(dir string, env *gocode_env) {
+	m.scope = new_scope(g_universe_scope)
+	m.main = new_decl(m.name, decl_package, nil)
+	m.others = make(map[string]*decl)
+
+	bp, err := env.build_context().ImportDir(dir, 0)
+	if err != nil {
+		return
+	}
+	m.defalias = bp.Name
+
+	fset := token.NewFileSet()
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		file, _ := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if file == nil {
+			continue
+		}
+
+		for _, imp := range file.Imports {
+			path, alias := path_and_alias(imp)
+			if alias == "" {
+				alias = default_alias(path)
+			}
+			if alias != "_" && alias != "." {
+				m.scope.add_decl(alias, new_decl(path, decl_package, nil))
+			}
+		}
+
+		for _, decl := range file.Decls {
+			anonymify_ast(decl, decl_foreign, m.scope)
+			add_ast_decl_to_package(m.main, decl, m.scope)
+		}
+	}
+
+	// unlike the archive, the source refers to the package's own types without qualification
+	for name, d := range m.main.children {
+		if d.class == decl_type {
+			m.scope.replace_decl(name, d)
+		}
+	}
+}
+
+// Guesses the name of the package from its import path, the way goimports does when it can't find the package.
+func default_alias(imp string) string {
+	name := path.Base(imp)
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,199 @@
+package gocode
+
+import (
+	"go/ast"
+	"go/parser"
+	"unicode"
+)
+
+//-------------------------------------------------------------------------
+// candidate ranking
+//
+// Each candidate is scored by how well its name matches the partial
+// identifier, where it's declared, whether its type is the one expected at
+// the cursor and whether it was accepted recently.
+//-------------------------------------------------------------------------
+
+const (
+	rank_local    = 30 // declared in the function around the cursor
+	rank_package  = 20 // package-level declarations and the members of packages and types
+	rank_expected = 40 // the value has the type expected at the cursor
+	rank_recent   = 20 // the most recently accepted name, older ones get less
+
+	margo_recent_max = rank_recent
+)
+
+func (b *out_buffers) matches(name, partial string) bool {
+	if g_config.ProposeFuzzy {
+		_, ok := FuzzyMatch(partial, name)
+		return ok
+	}
+	return has_prefix(name, partial, b.ignorecase)
+}
+
+func (b *out_buffers) score(partial, name string, d *decl, typ string) int {
+	expected := b.expected != "" && value_type(d, typ) == b.expected
+	return rank(partial, name, b.locals[d], d.scope == g_universe_scope, expected, b.ctx.recent[name])
+}
+
+// Returns the score of the candidate `name`, recent is its boost for having been accepted recently.
+func rank(partial, name string, local, universe, expected bool, recent int) int {
+	score, _ := FuzzyMatch(partial, name)
+	switch {
+	case local:
+		score += rank_local
+	case !universe:
+		score += rank_package
+	}
+	if expected {
+		score += rank_expected
+	}
+	return score + recent
+}
+
+// Returns the type of the value that the decl evaluates to, funcs evaluate to their result.
+func value_type(d *decl, typ string) string {
+	switch d.class {
+	case decl_var, decl_const:
+		return typ
+	case decl_func:
+		t, ok := d.typ.(*ast.FuncType)
+		if ok && t.Results != nil && len(t.Results.List) == 1 && len(t.Results.List[0].Names) <= 1 {
+			return type_string(t.Results.List[0].Type)
+		}
+	}
+	return ""
+}
+
+// Returns the decls declared inside the function around the cursor.
+func (c *auto_complete_context) local_decls() map[*decl]bool {
+	locals := make(map[*decl]bool)
+	for s := c.current.scope; s != nil && s != c.current.filescope; s = s.parent {
+		for _, d := range s.entities {
+			locals[d] = true
+		}
+	}
+	return locals
+}
+
+var g_assign_op_chars = map[byte]bool{
+	'=': true, '!': true, '<': true, '>': true, '+': true, '-': true,
+	'*': true, '/': true, '%': true, '&': true, '|': true, '^': true,
+}
+
+// Deduces the pretty-printed type that is expected at `cursor` (the start of the
+// partial identifier) from the assignment or comparison, or the call argument
+// that it's a part of. Returns "" if the type can't be deduced.
+func (c *auto_complete_context) deduce_expected_type(file []byte, cursor int) string {
+	iter := bytes_iterator{file, cursor}
+	prev := func() bool {
+		for iter.cursor > 0 {
+			iter.move_backwards()
+			if !unicode.IsSpace(iter.rune()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !prev() {
+		return ""
+	}
+	switch iter.char() {
+	case '=':
+		// `x = `, `x += `, `x == ` etc. expect the type of x, `x := ` doesn't expect anything
+		for i := 0; i < 3 && iter.cursor > 0; i++ {
+			iter.move_backwards()
+			if iter.char() == ':' {
+				return ""
+			}
+			if !g_assign_op_chars[iter.char()] {
+				break
+			}
+		}
+		if unicode.IsSpace(iter.rune()) && !prev() {
+			return ""
+		}
+		iter.cursor++
+		return c.expr_type_string(iter.extract_go_expr())
+	case '(', ',':
+		return c.deduce_arg_type(&iter)
+	}
+	return ""
+}
+
+// Deduces the type of the call argument that follows the '(' or ',' under the iterator.
+func (c *auto_complete_context) deduce_arg_type(iter *bytes_iterator) string {
+	arg := 0
+	depth := 0
+	for n := 0; n < 4096; n++ {
+		switch iter.char() {
+		case ')', ']', '}':
+			depth++
+		case '[', '{':
+			if depth == 0 {
+				return ""
+			}
+			depth--
+		case '(':
+			if depth == 0 {
+				return c.param_type_string(iter.extract_go_expr(), arg)
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				arg++
+			}
+		case ';':
+			return ""
+		}
+		if iter.cursor == 0 {
+			return ""
+		}
+		iter.move_backwards()
+	}
+	return ""
+}
+
+func (c *auto_complete_context) expr_type_string(e []byte) string {
+	expr, err := parser.ParseExpr(string(e))
+	if err != nil {
+		return ""
+	}
+	t, _, _ := infer_type(expr, c.current.scope, -1)
+	if t == nil {
+		return ""
+	}
+	return type_string(t)
+}
+
+func (c *auto_complete_context) param_type_string(fun []byte, arg int) string {
+	expr, err := parser.ParseExpr(string(fun))
+	if err != nil {
+		return ""
+	}
+	t, scope, _ := infer_type(expr, c.current.scope, -1)
+	t, _ = snippet_underlying(t, scope)
+	ft, ok := t.(*ast.FuncType)
+	if !ok || ft.Params == nil {
+		return ""
+	}
+
+	params := []ast.Expr{}
+	for _, field := range ft.Params.List {
+		for i := 0; i < len(field.Names) || (i == 0 && len(field.Names) == 0); i++ {
+			params = append(params, field.Type)
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	last := params[len(params)-1]
+	if ell, ok := last.(*ast.Ellipsis); ok && arg >= len(params)-1 {
+		return type_string(ell.Elt)
+	}
+	if arg >= len(params) {
+		return ""
+	}
+	return type_string(params[arg])
+}
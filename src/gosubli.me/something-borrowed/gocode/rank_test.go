@@ -0,0 +1,119 @@
+package gocode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const rank_test_src = `package p
+
+var total int
+
+func sum(values []int) int { return 0 }
+
+func join(sep string, parts ...string) string { return "" }
+
+func f(tally string) {
+	var tot int
+	%s
+}
+`
+
+// Returns the source of a file whose function body is `body`, and the offset of the `|` in it.
+func rank_test_file(body string) ([]byte, int) {
+	src := strings.Replace(rank_test_src, "%s", body, 1)
+	pos := strings.Index(src, "|")
+	return []byte(src[:pos] + src[pos+1:]), pos
+}
+
+func rank_test_dir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gocode-rank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestMargoRanking(t *testing.T) {
+	dir := rank_test_dir(t)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "p.go")
+	src, pos := rank_test_file("tot = t|")
+
+	names := func(fuzzy bool) ([]string, map[string]int) {
+		m := newMargoState()
+		l := []string{}
+		scores := map[string]int{}
+		for _, c := range m.Complete(MargoConfig{Fuzzy: fuzzy}, src, fn, pos) {
+			l = append(l, c.Name)
+			scores[c.Name] = c.Score
+		}
+		return l, scores
+	}
+
+	// without fuzzy matching, the order is unchanged: by class, then by name
+	l, scores := names(false)
+	if want := []string{"tally", "tot", "total"}; !reflect.DeepEqual(l, want) {
+		t.Errorf("got %v, want %v", l, want)
+	}
+	// locals beat package-level names, and the expected type (int) is preferred
+	if !(scores["tot"] > scores["total"] && scores["total"] > scores["tally"]) {
+		t.Errorf("want tot > total > tally, got %v", scores)
+	}
+
+	l, _ = names(true)
+	if want := []string{"tot", "total", "tally"}; !reflect.DeepEqual(l, want) {
+		t.Errorf("fuzzy: got %v, want %v", l, want)
+	}
+}
+
+func TestMargoRecent(t *testing.T) {
+	m := newMargoState()
+	before := m.Score("t", "tally", false, false)
+	m.Accepted("tally")
+	m.Accepted("total")
+	if got := m.Score("t", "total", false, false); got != before+rank_recent {
+		t.Errorf("the most recent name got %d, want %d", got, before+rank_recent)
+	}
+	if got := m.Score("t", "tally", false, false); got != before+rank_recent-1 {
+		t.Errorf("the older name got %d, want %d", got, before+rank_recent-1)
+	}
+	if local, pkg, universe := m.Score("", "x", true, false), m.Score("", "x", false, false), m.Score("", "x", false, true); !(local > pkg && pkg > universe) {
+		t.Errorf("want local(%d) > package(%d) > universe(%d)", local, pkg, universe)
+	}
+}
+
+func TestDeduceExpectedType(t *testing.T) {
+	dir := rank_test_dir(t)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "p.go")
+
+	// the partial identifier after the cursor keeps the statement valid, so the locals are found
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{"tot = |t", "int"},
+		{"tot += |t", "int"},
+		{"ok := tally == |t", "string"},
+		{"x := |t", ""},
+		{"sum(|t", "[]int"},
+		{"join(tally, |t", "string"},
+		{"join(tally, tally, |t", "string"},
+		{"sum(join(), |t", ""},
+		{"x := []int{|t", ""},
+		{"|t", ""},
+	}
+	for _, test := range tests {
+		src, pos := rank_test_file(test.body)
+		c := new_auto_complete_context(new_package_cache(), new_decl_cache(&gocode_env{}))
+		c.apropos(src, fn, pos)
+		if got := c.deduce_expected_type(src, pos); got != test.expected {
+			t.Errorf("%q: got %q, want %q", test.body, got, test.expected)
+		}
+	}
+}
@@ -114,7 +114,7 @@ func server_auto_complete(file []byte, filename string, cursor int, env gocode_e
 		if err := recover(); err != nil {
 			print_backtrace(err)
 			c = []candidate{
-				{"PANIC", "PANIC", decl_invalid},
+				{"PANIC", "PANIC", decl_invalid, "", 0},
 			}
 
 			// drop cache
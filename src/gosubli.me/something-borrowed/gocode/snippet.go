@@ -0,0 +1,265 @@
+package gocode
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+//-------------------------------------------------------------------------
+// snippets
+//
+// Snippets are written in the TextMate/Sublime Text format: ${N:text} is a
+// placeholder, $0 is where the cursor ends up.
+//-------------------------------------------------------------------------
+
+var snippet_escaper = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+
+func snippet_escape(s string) string {
+	return snippet_escaper.Replace(s)
+}
+
+type snippet_buffer struct {
+	bytes.Buffer
+	n int
+}
+
+// Writes the next placeholder with the default text s.
+func (b *snippet_buffer) placeholder(s string) {
+	b.n++
+	fmt.Fprintf(b, "${%d:%s}", b.n, snippet_escape(s))
+}
+
+func type_string(e ast.Expr) string {
+	var buf bytes.Buffer
+	pretty_print_type_expr(&buf, e)
+	return buf.String()
+}
+
+// Returns a copy of the type expression with the types of its own package qualified by alias, so it
+// can be written in the current file. the types are unqualified in the declarations parsed from source
+// and qualified by `#defalias` in those read from the export data.
+func qualify_type(e ast.Expr, alias string) ast.Expr {
+	if alias == "" {
+		return e
+	}
+	switch t := e.(type) {
+	case *ast.Ident:
+		if ast.IsExported(t.Name) {
+			return &ast.SelectorExpr{X: ast.NewIdent(alias), Sel: t}
+		}
+	case *ast.SelectorExpr:
+		if id, ok := t.X.(*ast.Ident); ok && strings.HasPrefix(id.Name, "#") {
+			return &ast.SelectorExpr{X: ast.NewIdent(alias), Sel: t.Sel}
+		}
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: qualify_type(t.X, alias)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: qualify_type(t.Elt, alias)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: t.Len, Elt: qualify_type(t.Elt, alias)}
+	case *ast.MapType:
+		return &ast.MapType{Key: qualify_type(t.Key, alias), Value: qualify_type(t.Value, alias)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: t.Dir, Value: qualify_type(t.Value, alias)}
+	case *ast.FuncType:
+		return &ast.FuncType{
+			Params:  qualify_fields(t.Params, alias),
+			Results: qualify_fields(t.Results, alias),
+		}
+	}
+	return e
+}
+
+func qualify_fields(fl *ast.FieldList, alias string) *ast.FieldList {
+	if fl == nil {
+		return nil
+	}
+	list := make([]*ast.Field, len(fl.List))
+	for i, f := range fl.List {
+		list[i] = &ast.Field{Names: f.Names, Type: qualify_type(f.Type, alias)}
+	}
+	return &ast.FieldList{List: list}
+}
+
+// Returns the package name written before the `.` that precedes offset i, e.g. `io` in `io.Wr`.
+func qualifier_at(file []byte, i int) string {
+	if i <= 0 || i > len(file) || file[i-1] != '.' {
+		return ""
+	}
+	j := i - 1
+	for j > 0 && is_ident_byte(file[j-1]) {
+		j--
+	}
+	return string(file[j : i-1])
+}
+
+func is_ident_byte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// Returns the name of a parameter as it's written in the source, export data may decorate it.
+func param_name(name string) string {
+	if i := strings.Index(name, "·"); i >= 0 {
+		name = name[:i]
+	}
+	if name == "?" || name == "_" {
+		return ""
+	}
+	return name
+}
+
+// Resolves anonymous and named types to the type expression they stand for.
+func snippet_underlying(t ast.Expr, scope *scope) (ast.Expr, *scope) {
+	for i := 0; i < 10 && t != nil; i++ {
+		switch t.(type) {
+		case *ast.Ident, *ast.SelectorExpr:
+			d := type_to_decl(t, scope)
+			if d == nil || d.class != decl_type || d.typ == nil {
+				return t, scope
+			}
+			t, scope = d.typ, d.scope
+		default:
+			return t, scope
+		}
+	}
+	return t, scope
+}
+
+// `Name(${1:a}, ${2:b...})` for functions, using the parameter types as placeholders for unnamed parameters.
+// alias qualifies the types of a function from another package.
+func func_snippet(name string, t *ast.FuncType, alias string) string {
+	b := &snippet_buffer{}
+	b.WriteString(name)
+	b.WriteString("(")
+	if t.Params != nil {
+		for _, field := range t.Params.List {
+			suffix := ""
+			typ := field.Type
+			if ell, ok := typ.(*ast.Ellipsis); ok {
+				suffix = "..."
+				typ = ell.Elt
+			}
+
+			names := []string{}
+			for _, id := range field.Names {
+				names = append(names, param_name(id.Name))
+			}
+			if len(names) == 0 {
+				names = append(names, "")
+			}
+			for _, s := range names {
+				if b.n > 0 {
+					b.WriteString(", ")
+				}
+				if s == "" {
+					s = type_string(qualify_type(typ, alias))
+				}
+				b.placeholder(s + suffix)
+			}
+		}
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// A composite literal of the struct type with a placeholder for each field.
+// only the exported fields of types from other packages are included.
+func struct_snippet(name string, d *decl, alias string) string {
+	t, _ := snippet_underlying(d.typ, d.scope)
+	st, ok := t.(*ast.StructType)
+	if !ok || st.Fields == nil {
+		return ""
+	}
+
+	b := &snippet_buffer{}
+	b.WriteString(name)
+	b.WriteString("{\n")
+	for _, field := range st.Fields.List {
+		names := []string{}
+		for _, id := range field.Names {
+			names = append(names, id.Name)
+		}
+		if len(names) == 0 {
+			// embedded fields are named after their type
+			tp := get_type_path(field.Type)
+			names = append(names, strings.TrimPrefix(tp.name, "#"))
+		}
+
+		for _, s := range names {
+			if s == "" || s == "_" || (d.flags&decl_foreign != 0 && !ast.IsExported(s)) {
+				continue
+			}
+			b.WriteString("\t" + s + ": ")
+			b.placeholder(type_string(qualify_type(field.Type, alias)))
+			b.WriteString(",\n")
+		}
+	}
+	if b.n == 0 {
+		return name + "{}$0"
+	}
+	b.WriteString("}$0")
+	return b.String()
+}
+
+// A `for range` statement over the variable if it's a slice, array, map or channel.
+func range_snippet(name string, d *decl) string {
+	t, scope := d.infer_type()
+	t, _ = snippet_underlying(t, scope)
+	switch t.(type) {
+	case *ast.ArrayType:
+		return "for ${1:i}, ${2:v} := range " + snippet_escape(name) + " {\n\t$0\n}"
+	case *ast.MapType:
+		return "for ${1:k}, ${2:v} := range " + snippet_escape(name) + " {\n\t$0\n}"
+	case *ast.ChanType:
+		return "for ${1:v} := range " + snippet_escape(name) + " {\n\t$0\n}"
+	}
+	return ""
+}
+
+// Method declarations that implement the interface type, the receiver is the same placeholder in all of them.
+// alias qualifies the types of an interface from another package.
+func interface_snippet(d *decl, alias string) string {
+	methods := []*ast.Field{}
+	seen := map[*decl]bool{}
+	var collect func(t ast.Expr, scope *scope)
+	collect = func(t ast.Expr, scope *scope) {
+		t, scope = snippet_underlying(t, scope)
+		it, ok := t.(*ast.InterfaceType)
+		if !ok || it.Methods == nil {
+			return
+		}
+		for _, field := range it.Methods.List {
+			if _, ok := field.Type.(*ast.FuncType); ok {
+				methods = append(methods, field)
+				continue
+			}
+			// embedded interface
+			if ed := type_to_decl(field.Type, scope); ed != nil && !seen[ed] {
+				seen[ed] = true
+				collect(ed.typ, ed.scope)
+			}
+		}
+	}
+	collect(d.typ, d.scope)
+	if len(methods) == 0 {
+		return ""
+	}
+
+	var b bytes.Buffer
+	for i, field := range methods {
+		ft := field.Type.(*ast.FuncType)
+		for _, id := range field.Names {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			// strip the `func` from the type, leaving the signature
+			sig := strings.TrimPrefix(type_string(qualify_type(ft, alias)), "func")
+			fmt.Fprintf(&b, "func (${1:r} ${2:*T}) %s%s {\n\tpanic(\"not implemented\")\n}",
+				id.Name, snippet_escape(sig))
+		}
+	}
+	b.WriteString("$0")
+	return b.String()
+}